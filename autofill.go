@@ -0,0 +1,94 @@
+package gosubmit
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Provides values for AutoFill, see WithAutoFill. Returning nil falls back
+// to the default value of the input.
+type AutoFillProvider interface {
+	AutoFill(input Input) []string
+}
+
+// Seed of the random generator used by AutoFill, unless WithSeed is used.
+const DefaultSeed int64 = 1
+
+// Generates values for autofilling inputs.
+type generator struct {
+	now  func() time.Time
+	rand *rand.Rand
+	// generate text with non-ASCII characters
+	unicode bool
+	// optional provider which is asked for values first
+	provider AutoFillProvider
+	// generates values for inputs with an autocomplete attribute
+	faker *Faker
+}
+
+func newGenerator() *generator {
+	g := &generator{
+		now: func() time.Time {
+			return autoFillTime
+		},
+	}
+	g.seed(DefaultSeed)
+	return g
+}
+
+func (g *generator) seed(seed int64) {
+	g.faker = NewFaker(seed)
+	g.rand = g.faker.rand
+}
+
+const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Letters used for unicode text. All of them are a single UTF-16 code unit
+// long, except for the emoji.
+var (
+	unicodeLetters = []rune("aeioućčđšžĆČĐŠŽäöüßéñøåłλπжщ日本語字")
+	unicodeEmoji   = []rune("😀🎉🚀")
+)
+
+// Returns a random string of letters which is length UTF-16 code units long,
+// the way browsers measure minlength and maxlength.
+func (g *generator) randomString(length int) string {
+	var b strings.Builder
+	for length > 0 {
+		if !g.unicode {
+			b.WriteByte(letters[g.rand.Intn(len(letters))])
+			length--
+			continue
+		}
+		if length > 1 && g.rand.Intn(5) == 0 {
+			b.WriteRune(unicodeEmoji[g.rand.Intn(len(unicodeEmoji))])
+			length -= 2
+			continue
+		}
+		b.WriteRune(unicodeLetters[g.rand.Intn(len(unicodeLetters))])
+		length--
+	}
+	return b.String()
+}
+
+// Implemented by inputs which use the generator when autofilling.
+type generatedInput interface {
+	generate(g *generator) []string
+}
+
+// Returns values for the input.
+func (g *generator) autoFill(input Input) []string {
+	if g.provider != nil {
+		if values := g.provider.AutoFill(input); values != nil {
+			return values
+		}
+	}
+	if values := g.faker.autoFillAutocomplete(input); values != nil {
+		return values
+	}
+	if i, ok := input.(generatedInput); ok {
+		return i.generate(g)
+	}
+	return input.AutoFill()
+}
@@ -0,0 +1,112 @@
+package gosubmit
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+const charsetUTF8 = "utf-8"
+
+// Number of bytes checked for a <meta charset> element, like browsers do.
+const charsetPrescanLength = 1024
+
+// Detects the charset of the document from the byte order mark, the charset
+// parameter of the Content-Type header or a <meta charset> element, and
+// returns a reader which decodes the document to UTF-8. Documents without a
+// declared charset are assumed to be UTF-8. The encoding is nil for UTF-8.
+func decodeDocument(r io.Reader, contentType string) (io.Reader, encoding.Encoding, string) {
+	br := bufio.NewReader(r)
+	prefix, _ := br.Peek(charsetPrescanLength)
+	// only the byte order mark and the Content-Type header are certain
+	e, name, certain := charset.DetermineEncoding(prefix, contentType)
+	if !certain {
+		e, name = getMetaCharset(prefix)
+	}
+	if e == nil || name == charsetUTF8 {
+		return br, nil, charsetUTF8
+	}
+	return e.NewDecoder().Reader(br), e, name
+}
+
+// Returns the encoding declared by the first <meta charset> or <meta
+// http-equiv="content-type"> element in the prefix of the document, or nil
+// when there is none.
+func getMetaCharset(prefix []byte) (encoding.Encoding, string) {
+	z := html.NewTokenizer(bytes.NewReader(prefix))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return nil, ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tagName, hasAttr := z.TagName()
+			if string(tagName) != "meta" {
+				continue
+			}
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = z.TagAttr()
+				attrs[string(key)] = string(value)
+			}
+			label, ok := attrs["charset"]
+			if !ok && strings.EqualFold(attrs["http-equiv"], "content-type") {
+				_, params, err := mime.ParseMediaType(attrs["content"])
+				if err == nil {
+					label, ok = params["charset"]
+				}
+			}
+			if !ok {
+				continue
+			}
+			e, name := charset.Lookup(label)
+			if e == nil {
+				continue
+			}
+			// documents are never decoded as UTF-16 based on <meta>
+			if strings.HasPrefix(name, "utf-16") {
+				return nil, charsetUTF8
+			}
+			return e, name
+		}
+	}
+}
+
+// Returns the encoding of the first supported charset in the accept-charset
+// attribute of the form. Returns false when there is none.
+func getAcceptCharset(n *html.Node) (encoding.Encoding, string, bool) {
+	labels := strings.FieldsFunc(getAttr(n, "accept-charset"), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+	})
+	for _, label := range labels {
+		e, name := charset.Lookup(label)
+		if e == nil {
+			continue
+		}
+		if name == charsetUTF8 {
+			return nil, name, true
+		}
+		return e, name, true
+	}
+	return nil, "", false
+}
+
+// Encodes a name or value using the charset of the form. Characters which
+// are not supported by the charset are replaced with HTML character
+// references like &#269;, like browsers do.
+func encodeCharset(e encoding.Encoding, str string) string {
+	if e == nil {
+		return str
+	}
+	encoded, err := e.NewEncoder().String(str)
+	if err != nil {
+		return str
+	}
+	return encoded
+}
@@ -0,0 +1,347 @@
+package gosubmit
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"unicode"
+)
+
+var (
+	fakeFirstNames = []string{"Ana", "John", "Maria", "Ivan", "Emma", "Luka", "Olivia", "Noah"}
+	fakeLastNames  = []string{"Horvat", "Smith", "Kovač", "Johnson", "Novak", "Brown", "Garcia", "Miller"}
+	fakeCities     = []string{"Zagreb", "Springfield", "Berlin", "Lisbon", "Toronto", "Melbourne"}
+	fakeStreets    = []string{"Main Street", "Oak Avenue", "Ilica", "Elm Road", "Maple Lane"}
+	fakeCountries  = []string{"Croatia", "United States", "Germany", "Portugal", "Canada"}
+	fakeCompanies  = []string{"Acme Inc.", "Globex", "Initech", "Umbrella Corp."}
+)
+
+// An AutoFillProvider which generates realistic values for text inputs, like
+// names, addresses and phone numbers. The kind of value is guessed from the
+// input type and name, so an input named first_name gets a first name and an
+// input named zip gets a postal code. Values which are not valid for the
+// input (because of a pattern or length constraints) are not used.
+//
+// The autocomplete attribute takes precedence over the name, and is honoured
+// by AutoFill even without a Faker.
+//
+// The same seed always generates the same values, as long as a new Faker is
+// used for each form.
+type Faker struct {
+	rand *rand.Rand
+	// generated once, so password confirmation fields get the same value
+	// (see fillNewPassword)
+	newPassword string
+}
+
+// Creates a new Faker using the seed.
+func NewFaker(seed int64) *Faker {
+	return &Faker{
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Returns a generated value, or nil when the kind of value cannot be guessed
+// or the value is invalid.
+func (f *Faker) AutoFill(input Input) []string {
+	kind := input.Autocomplete()
+	if _, ok := fakeKinds[kind]; !ok {
+		kind = guessKind(input)
+	}
+	return f.fill(input, kind)
+}
+
+// Returns a value for the autofill field name of the input, or nil when the
+// autocomplete attribute is missing or not supported.
+func (f *Faker) autoFillAutocomplete(input Input) []string {
+	return f.fill(input, input.Autocomplete())
+}
+
+func (f *Faker) fill(input Input, kind string) []string {
+	if !isTextual(input) {
+		return nil
+	}
+	if kind == fakeNewPassword {
+		return f.fillNewPassword(input)
+	}
+	value := f.fake(kind)
+	if value == "" {
+		return nil
+	}
+	value, ok := input.Fill(value)
+	if !ok {
+		return nil
+	}
+	return []string{value}
+}
+
+// All new-password fields get the same password, so password confirmation
+// fields match. The password is generated once, and satisfies the length
+// and pattern constraints of the first field.
+func (f *Faker) fillNewPassword(input Input) []string {
+	if f.newPassword == "" {
+		value, ok := input.Fill(f.password())
+		if !ok {
+			value, ok = f.generate(input)
+		}
+		if !ok {
+			return nil
+		}
+		f.newPassword = value
+	}
+	value, ok := input.Fill(f.newPassword)
+	if !ok {
+		return nil
+	}
+	return []string{value}
+}
+
+// Generates a random value which satisfies the constraints of the input,
+// like AutoFill does without a Faker.
+func (f *Faker) generate(input Input) (string, bool) {
+	i, ok := input.(generatedInput)
+	if !ok {
+		return "", false
+	}
+	g := newGenerator()
+	g.faker = f
+	g.rand = f.rand
+	values := i.generate(g)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// Kinds of values generated by the Faker. Most of them are autofill field
+// names of the autocomplete attribute.
+const (
+	fakeEmail           = "email"
+	fakeFirstName       = "given-name"
+	fakeMiddleName      = "additional-name"
+	fakeLastName        = "family-name"
+	fakeName            = "name"
+	fakeNickname        = "nickname"
+	fakeUsername        = "username"
+	fakeNewPassword     = "new-password"
+	fakeCurrentPassword = "current-password"
+	fakeOneTimeCode     = "one-time-code"
+	fakeTel             = "tel"
+	fakeTelNational     = "tel-national"
+	fakePostcode        = "postal-code"
+	fakeCity            = "address-level2"
+	fakeStreet          = "street-address"
+	fakeAddressLine1    = "address-line1"
+	fakeCountry         = "country"
+	fakeCountryName     = "country-name"
+	fakeCompany         = "organization"
+	fakeURL             = "url"
+	fakeCardName        = "cc-name"
+	fakeCardNumber      = "cc-number"
+	fakeCardExpiry      = "cc-exp"
+	fakeCardExpiryMonth = "cc-exp-month"
+	fakeCardExpiryYear  = "cc-exp-year"
+	fakeCardCode        = "cc-csc"
+)
+
+var fakeKinds = map[string]struct{}{}
+
+func init() {
+	for _, kind := range []string{
+		fakeEmail, fakeFirstName, fakeMiddleName, fakeLastName, fakeName,
+		fakeNickname, fakeUsername, fakeNewPassword, fakeCurrentPassword,
+		fakeOneTimeCode, fakeTel, fakeTelNational, fakePostcode, fakeCity,
+		fakeStreet, fakeAddressLine1, fakeCountry, fakeCountryName,
+		fakeCompany, fakeURL, fakeCardName, fakeCardNumber, fakeCardExpiry,
+		fakeCardExpiryMonth, fakeCardExpiryYear, fakeCardCode,
+	} {
+		fakeKinds[kind] = struct{}{}
+	}
+}
+
+// Name heuristics, checked in order so that more specific names (like
+// username) are matched before less specific ones (like name). A hint
+// matches one or more consecutive words of the name, so firstname matches
+// first_name and firstName, but not surname.
+var fakeNameHints = []struct {
+	kind  string
+	hints []string
+}{
+	{fakeEmail, []string{"email", "mail"}},
+	{fakeFirstName, []string{"firstname", "givenname", "fname", "forename"}},
+	{fakeLastName, []string{"lastname", "familyname", "surname", "lname"}},
+	{fakeUsername, []string{"username", "login", "nick", "nickname"}},
+	{fakeNewPassword, []string{"password", "passwd", "pass"}},
+	{fakeTel, []string{"phone", "mobile", "tel", "telephone"}},
+	{fakePostcode, []string{"zip", "zipcode", "postcode", "postalcode"}},
+	{fakeCity, []string{"city", "town"}},
+	{fakeStreet, []string{"street", "address"}},
+	{fakeCountryName, []string{"country"}},
+	{fakeCompany, []string{"company", "organization", "organisation"}},
+	{fakeURL, []string{"website", "homepage", "url"}},
+	{fakeName, []string{"name", "fullname"}},
+}
+
+// Returns true for inputs which accept free-form text.
+func isTextual(input Input) bool {
+	switch input.(type) {
+	case TextInput, TextArea, EmailInput, URLInput, TelInput, SearchInput, PasswordInput:
+		return true
+	}
+	return false
+}
+
+func guessKind(input Input) string {
+	switch input.Type() {
+	case InputTypeEmail:
+		return fakeEmail
+	case InputTypeTel:
+		return fakeTel
+	case InputTypeURL:
+		return fakeURL
+	case InputTypePassword:
+		return fakeNewPassword
+	}
+	words := splitName(input.Name())
+	for _, hint := range fakeNameHints {
+		for _, h := range hint.hints {
+			if containsWords(words, h) {
+				return hint.kind
+			}
+		}
+	}
+	return ""
+}
+
+// Splits the name into lowercase words on characters other than letters and
+// digits and on camelCase boundaries, so that first_name, firstName and
+// user[first-name] all contain the words first and name.
+func splitName(name string) (words []string) {
+	runes := []rune(name)
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	for i, r := range runes {
+		if !isAlphanumeric(r) {
+			flush()
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			// the last capital of an acronym starts a new word, like in
+			// URLField
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				flush()
+			}
+		}
+		word = append(word, unicode.ToLower(r))
+	}
+	flush()
+	return
+}
+
+// Returns true when one or more consecutive words joined together are the
+// same as hint.
+func containsWords(words []string, hint string) bool {
+	for i := range words {
+		joined := ""
+		for _, word := range words[i:] {
+			joined += word
+			if joined == hint {
+				return true
+			}
+			if len(joined) >= len(hint) {
+				break
+			}
+		}
+	}
+	return false
+}
+
+func (f *Faker) pick(values []string) string {
+	return values[f.rand.Intn(len(values))]
+}
+
+func (f *Faker) digits(count int) string {
+	var b strings.Builder
+	for i := 0; i < count; i++ {
+		b.WriteByte(byte('0' + f.rand.Intn(10)))
+	}
+	return b.String()
+}
+
+func (f *Faker) fake(kind string) string {
+	switch kind {
+	case fakeEmail:
+		return fmt.Sprintf("%s.%s@example.com", strings.ToLower(f.pick(fakeFirstNames)), f.digits(3))
+	case fakeFirstName, fakeMiddleName:
+		return f.pick(fakeFirstNames)
+	case fakeLastName:
+		return f.pick(fakeLastNames)
+	case fakeName, fakeCardName:
+		return f.pick(fakeFirstNames) + " " + f.pick(fakeLastNames)
+	case fakeUsername, fakeNickname:
+		return strings.ToLower(f.pick(fakeFirstNames)) + f.digits(3)
+	case fakeNewPassword, fakeCurrentPassword:
+		return f.password()
+	case fakeOneTimeCode:
+		return f.digits(6)
+	case fakeTel:
+		return "+1555" + f.digits(7)
+	case fakeTelNational:
+		return "555" + f.digits(7)
+	case fakePostcode:
+		return f.digits(5)
+	case fakeCity:
+		return f.pick(fakeCities)
+	case fakeStreet, fakeAddressLine1:
+		return fmt.Sprintf("%d %s", 1+f.rand.Intn(200), f.pick(fakeStreets))
+	case fakeCountry:
+		return "US"
+	case fakeCountryName:
+		return f.pick(fakeCountries)
+	case fakeCompany:
+		return f.pick(fakeCompanies)
+	case fakeURL:
+		return "https://www." + strings.ToLower(f.pick(fakeFirstNames)) + ".example.com"
+	case fakeCardNumber:
+		return luhn("4" + f.digits(14))
+	case fakeCardExpiry:
+		return fmt.Sprintf("%02d/%02d", 1+f.rand.Intn(12), 30+f.rand.Intn(10))
+	case fakeCardExpiryMonth:
+		return fmt.Sprintf("%02d", 1+f.rand.Intn(12))
+	case fakeCardExpiryYear:
+		return itoa(2030 + f.rand.Intn(10))
+	case fakeCardCode:
+		return f.digits(3)
+	}
+	return ""
+}
+
+func (f *Faker) password() string {
+	return "Pw-" + f.digits(4) + "-" + strings.ToLower(f.pick(fakeFirstNames))
+}
+
+// Appends the Luhn check digit to a number, like the last digit of credit
+// card numbers.
+func luhn(number string) string {
+	sum := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		// digits are doubled starting from the rightmost one, because the
+		// check digit is appended to the right
+		if (len(number)-i)%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return number + itoa((10-sum%10)%10)
+}
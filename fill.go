@@ -3,19 +3,37 @@ package gosubmit
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sort"
+	"strings"
+	"time"
 )
 
 type Option func(f *filler) error
 
 type multipartFile struct {
+	// set by AddFileReader, can only be read once
+	Reader io.Reader
+	// set by AddFile
 	Contents []byte
 	Name     string
+	// -1 when unknown
+	Size int64
+}
+
+// Returns a reader of the file contents. Files added using AddFile can be
+// read more than once.
+func (m multipartFile) open() io.Reader {
+	if m.Reader != nil {
+		return m.Reader
+	}
+	return bytes.NewReader(m.Contents)
 }
 
 type filler struct {
@@ -24,10 +42,30 @@ type filler struct {
 	values      url.Values
 	url         string
 	method      string
+	contentType string
 	clicked     bool
+	submitter   Button
+	noValidate  bool
+	generator   *generator
 	multipart   map[string][]multipartFile
-	required    map[string]struct{}
 	isMultipart bool
+	jar         http.CookieJar
+	// fields with invalid values, reported by validateForm
+	invalid []FieldError
+	// values of the fields in document order. Values of buttons and values
+	// set using UnsafeSet for unknown names are kept in values instead.
+	fields []fieldValues
+}
+
+// Values of a field. Fields sharing a name (like a hidden and a text input)
+// keep their own values, so setting one of them keeps the others.
+type fieldValues struct {
+	input  Input
+	values []string
+}
+
+func (v fieldValues) hasValue() bool {
+	return len(v.values) > 0 && v.values[0] != ""
 }
 
 // Creates a new form filler. It is preferred to use Form.Fill() instead.
@@ -36,18 +74,22 @@ func newFiller(form Form, opts []Option) (f *filler, err error) {
 	f = &filler{
 		form:        form,
 		values:      values,
-		required:    make(map[string]struct{}),
+		url:         form.URL,
+		method:      form.Method,
+		contentType: form.ContentType,
+		noValidate:  form.NoValidate,
+		generator:   newGenerator(),
 		multipart:   make(map[string][]multipartFile),
 		isMultipart: form.ContentType == ContentTypeMultipart,
 	}
-	f.prefill(form.Inputs)
+	f.prefill(form.fields())
 	err = f.apply(opts)
 	return
 }
 
 func (f *filler) apply(opts []Option) (err error) {
 	for _, opt := range opts {
-		err = opt(f)
+		err = f.collect(opt(f))
 		if err != nil {
 			return
 		}
@@ -55,18 +97,276 @@ func (f *filler) apply(opts []Option) (err error) {
 	return
 }
 
-func (f *filler) prefill(inputs Inputs) {
-	for name, input := range inputs {
-		if input.Required() {
-			f.required[name] = struct{}{}
+// Records the fields of a ValidationError so all invalid fields can be
+// reported at once by validateForm. Other errors are returned as is.
+func (f *filler) collect(err error) error {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		f.invalid = append(f.invalid, validationErr.Fields...)
+		return nil
+	}
+	return err
+}
+
+func (f *filler) prefill(fields []Input) {
+	for _, input := range fields {
+		field := fieldValues{input: input}
+		if !input.Disabled() && !input.Multipart() {
+			field.values = append([]string(nil), input.Values()...)
+		}
+		f.fields = append(f.fields, field)
+	}
+}
+
+// Returns the field changed by Set and Add. When fields share the name, the
+// first one which is not disabled or readonly is used, while ForceSet and
+// ForceAdd prefer disabled and readonly fields. Returns nil when there is no
+// field with the name.
+func (f *filler) target(name string, force bool) *fieldValues {
+	var first *fieldValues
+	for i := range f.fields {
+		field := &f.fields[i]
+		if field.input.Name() != name {
+			continue
+		}
+		if first == nil {
+			first = field
+		}
+		fillable := !field.input.Disabled() && !field.input.ReadOnly()
+		if fillable != force {
+			return field
+		}
+	}
+	return first
+}
+
+// Returns the first field with the name which matches, or nil.
+func (f *filler) findField(name string, matches func(input Input) bool) *fieldValues {
+	for i := range f.fields {
+		field := &f.fields[i]
+		if field.input.Name() == name && matches(field.input) {
+			return field
+		}
+	}
+	return nil
+}
+
+func isCheckable(input Input) bool {
+	switch input.(type) {
+	case Checkbox, Radio:
+		return true
+	}
+	return false
+}
+
+// A name=value pair or a file of the submitted form data.
+type entry struct {
+	name  string
+	value string
+	file  *multipartFile
+}
+
+// A control which contributes entries to the form data. Checkboxes and
+// radios only submit their own value, other controls submit the values of
+// their field.
+type slot struct {
+	name      string
+	checkable bool
+	option    string
+	file      bool
+	button    bool
+	// set for forms without controls
+	field *fieldValues
+}
+
+// Returns the submitted values and files in tree order of their controls,
+// the way browsers construct the form data. Values which do not belong to
+// any control (like values set using UnsafeSet) come last, sorted by name.
+func (f *filler) entries() (entries []entry) {
+	// fields other than checkboxes and radios, by name in document order
+	fields := map[string][]*fieldValues{}
+	// checked values of checkboxes and radios
+	checked := map[string][]string{}
+	for i := range f.fields {
+		field := &f.fields[i]
+		name := field.input.Name()
+		if isCheckable(field.input) {
+			checked[name] = append(checked[name], field.values...)
+		} else {
+			fields[name] = append(fields[name], field)
+		}
+	}
+	values := make(map[string][]string, len(f.values))
+	for name, v := range f.values {
+		values[name] = v
+	}
+	files := make(map[string][]multipartFile, len(f.multipart))
+	for name, v := range f.multipart {
+		files[name] = v
+	}
+
+	slots := f.slots()
+	fileSlots := map[string]int{}
+	for _, s := range slots {
+		if s.file {
+			fileSlots[s.name]++
 		}
-		if input.Multipart() {
+	}
+
+	add := func(name string, v []string) {
+		for _, value := range v {
+			entries = append(entries, entry{name: name, value: value})
+		}
+	}
+	// the last file input of a name takes the rest of the files, like a
+	// file input with the multiple attribute
+	takeFiles := func(name string, all bool) {
+		v := files[name]
+		if !all && len(v) > 1 {
+			v = v[:1]
+		}
+		for i := range v {
+			entries = append(entries, entry{name: name, file: &v[i]})
+		}
+		files[name] = files[name][len(v):]
+	}
+	for _, s := range slots {
+		switch {
+		case s.field != nil:
+			add(s.name, s.field.values)
+			if s.file {
+				takeFiles(s.name, true)
+			}
+		case s.checkable:
+			for j, value := range checked[s.name] {
+				if value == s.option {
+					add(s.name, []string{value})
+					checked[s.name] = remove(checked[s.name], j)
+					break
+				}
+			}
+		case s.file:
+			fileSlots[s.name]--
+			takeFiles(s.name, fileSlots[s.name] == 0)
+		case s.button:
+			for _, name := range f.submitterNames() {
+				add(name, values[name])
+				delete(values, name)
+			}
+		default:
+			if queue := fields[s.name]; len(queue) > 0 {
+				add(s.name, queue[0].values)
+				fields[s.name] = queue[1:]
+			}
+		}
+	}
+
+	var other []string
+	seen := map[string]struct{}{}
+	for _, m := range []map[string][]string{values, checked} {
+		for name, v := range m {
+			if _, ok := seen[name]; !ok && len(v) > 0 {
+				seen[name] = struct{}{}
+				other = append(other, name)
+			}
+		}
+	}
+	for name, v := range files {
+		if _, ok := seen[name]; !ok && len(v) > 0 {
+			other = append(other, name)
+		}
+	}
+	sort.Strings(other)
+	for _, name := range other {
+		takeFiles(name, true)
+		add(name, checked[name])
+		add(name, values[name])
+	}
+	return
+}
+
+// Returns the controls of the form in tree order. Only the clicked button is
+// included. Forms which were not parsed have no controls, so one slot is
+// returned for each field.
+func (f *filler) slots() (slots []slot) {
+	if f.form.controls == nil {
+		for i := range f.fields {
+			field := &f.fields[i]
+			slots = append(slots, slot{name: field.input.Name(), file: field.input.Multipart(), field: field})
+		}
+		return
+	}
+	for _, n := range f.form.controls {
+		inputType := strings.ToLower(getAttr(n, "type"))
+		s := slot{name: getAttr(n, "name")}
+		switch {
+		case n.Data == ElementButton && inputType != "reset" && inputType != "button",
+			n.Data == "input" && (inputType == InputTypeSubmit || inputType == InputTypeImage):
+			if !f.clicked || f.submitter.node != n {
+				continue
+			}
+			s.button = true
+		case n.Data == ElementButton:
+			continue
+		case n.Data == "input" && (inputType == InputTypeCheckbox || inputType == InputTypeRadio):
+			s.checkable = true
+			s.option = getCheckedValue(n)
+		case n.Data == "input" && inputType == InputTypeFile:
+			s.file = true
+		case n.Data == "input" && (inputType == "reset" || inputType == "button"):
+			continue
+		}
+		if s.name == "" && !s.button {
+			continue
+		}
+		slots = append(slots, s)
+	}
+	return
+}
+
+// Returns the names submitted by the clicked button.
+func (f *filler) submitterNames() []string {
+	b := f.submitter
+	if b.Type == InputTypeImage {
+		prefix := ""
+		if b.Name != "" {
+			prefix = b.Name + "."
+		}
+		return []string{prefix + "x", prefix + "y"}
+	}
+	if b.Name == "" {
+		return nil
+	}
+	return []string{b.Name}
+}
+
+func remove(values []string, i int) []string {
+	result := make([]string, 0, len(values)-1)
+	result = append(result, values[:i]...)
+	return append(result, values[i+1:]...)
+}
+
+// Converts line breaks to CRLF and encodes the string using the charset of
+// the form.
+func (f *filler) encodeString(str string) string {
+	return encodeCharset(f.form.encoding, normalizeNewlines(str))
+}
+
+// Encodes values as application/x-www-form-urlencoded in tree order.
+func (f *filler) encode() string {
+	var b strings.Builder
+	for _, field := range f.entries() {
+		if field.file != nil {
 			continue
 		}
-		for _, value := range input.Values() {
-			f.values.Add(name, value)
+		if b.Len() > 0 {
+			b.WriteByte('&')
 		}
+		b.WriteString(url.QueryEscape(f.encodeString(field.name)))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(f.encodeString(field.value)))
 	}
+	return b.String()
 }
 
 func (f *filler) createRequest(test bool, method string, url string, body io.Reader) (r *http.Request, err error) {
@@ -79,14 +379,55 @@ func (f *filler) createRequest(test bool, method string, url string, body io.Rea
 	}()
 	if test {
 		r = httptest.NewRequest(method, url, body)
+	} else {
+		ctx := f.context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		r, err = http.NewRequestWithContext(ctx, method, url, body)
+		if err != nil {
+			return
+		}
+	}
+	f.addHeaders(r)
+	return
+}
+
+// Adds the headers and cookies captured by ParseResponse to the request. When
+// a cookie jar is used (see WithCookieJar), the captured cookies are stored
+// in the jar first, and the cookies from the jar are added instead.
+func (f *filler) addHeaders(r *http.Request) {
+	for key, values := range f.form.Header {
+		for _, value := range values {
+			r.Header.Add(key, value)
+		}
+	}
+	if f.jar == nil {
+		now := time.Now()
+		for _, cookie := range f.form.Cookies {
+			expired := cookie.MaxAge < 0 || !cookie.Expires.IsZero() && cookie.Expires.Before(now)
+			if !expired {
+				r.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+			}
+		}
 		return
 	}
-	ctx := f.context
-	if ctx == nil {
-		ctx = context.Background()
+	if len(f.form.Cookies) > 0 && f.form.documentURL != nil {
+		f.jar.SetCookies(jarURL(f.form.documentURL), f.form.Cookies)
 	}
-	r, err = http.NewRequestWithContext(ctx, method, url, body)
-	return
+	for _, cookie := range f.jar.Cookies(jarURL(r.URL)) {
+		r.AddCookie(cookie)
+	}
+}
+
+// Cookie jars only work with absolute URLs, so relative URLs (like the ones
+// used in test requests) are resolved against the host of httptest.NewRequest.
+func jarURL(u *url.URL) *url.URL {
+	if u.IsAbs() {
+		return u
+	}
+	base, _ := url.Parse(sessionURL)
+	return base.ResolveReference(u)
 }
 
 func (f *filler) NewTestRequest() (*http.Request, error) {
@@ -99,40 +440,60 @@ func (f *filler) NewRequest() (*http.Request, error) {
 
 // Builds a form depeding on the enctype and creates a new test request.
 func (f *filler) prepareRequest(test bool) (r *http.Request, err error) {
-	form := f.form
-	switch form.Method {
+	switch f.method {
 	case http.MethodPost:
 		if !f.isMultipart {
 			body, err := f.BuildPost()
 			if err != nil {
 				return nil, err
 			}
-			r, err = f.createRequest(test, "POST", form.URL, bytes.NewReader(body))
+			r, err = f.createRequest(test, "POST", f.url, bytes.NewReader(body))
 			if err != nil {
 				err = fmt.Errorf("Error creating post request: %w", err)
 				return nil, err
 			}
-			r.Header.Add("Content-Type", form.ContentType)
-		} else {
+			r.Header.Add("Content-Type", f.contentType)
+		} else if !f.hasFileReaders() {
 			boundary, body, err := f.BuildMultipart()
 			if err != nil {
 				return nil, err
 			}
-			r, err = f.createRequest(test, "POST", form.URL, bytes.NewReader(body))
+			r, err = f.createRequest(test, "POST", f.url, bytes.NewReader(body))
 			if err != nil {
 				return nil, fmt.Errorf("Error creating multipart request: %w", err)
-
 			}
 			r.Header.Add("Content-Type",
 				fmt.Sprintf("%s; boundary=%s", ContentTypeMultipart, boundary))
+		} else {
+			// files added using AddFileReader are streamed, so they do not
+			// have to be kept in memory
+			boundary, length, err := f.prepareMultipart()
+			if err != nil {
+				return nil, err
+			}
+			body := f.streamMultipart(boundary)
+			r, err = f.createRequest(test, "POST", f.url, body)
+			if err != nil {
+				body.Close()
+				return nil, fmt.Errorf("Error creating multipart request: %w", err)
+			}
+			r.ContentLength = length
+			r.Header.Add("Content-Type",
+				fmt.Sprintf("%s; boundary=%s", ContentTypeMultipart, boundary))
 		}
 	default:
 		query, err := f.BuildGet()
 		if err != nil {
 			return nil, err
 		}
-		url := fmt.Sprintf("%s?%s", form.URL, query)
-		r, err = f.createRequest(test, "GET", url, nil)
+		// the query of the action is replaced, like browsers do
+		u, err := url.Parse(f.url)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing url '%s': %w", f.url, err)
+		}
+		u.RawQuery = query
+		u.Fragment = ""
+		r, err = f.createRequest(test, "GET", u.String(), nil)
 		if err != nil {
 			return nil, fmt.Errorf("Error creating get request: %w", err)
 		}
@@ -150,39 +511,115 @@ func (f *filler) BuildMultipart() (boundary string, data []byte, err error) {
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
 	boundary = writer.Boundary()
-	defer func() {
-		e := writer.Close()
-		if e != nil && err == nil {
-			err = fmt.Errorf("Error closing multipart writer: %s", e)
+	err = f.writeMultipart(writer, true)
+	return boundary, body.Bytes(), err
+}
+
+// Validates the form and returns the boundary and length of a multipart
+// body. The length is -1 when the size of any of the files is unknown.
+func (f *filler) prepareMultipart() (boundary string, length int64, err error) {
+	if err = f.validateForm(); err != nil {
+		return "", 0, err
+	}
+	boundary = multipart.NewWriter(nil).Boundary()
+	length, err = f.multipartLength(boundary)
+	return boundary, length, err
+}
+
+// Returns a multipart body which is written while it is being read, so files
+// added using AddFileReader are never buffered. The body must be read until
+// EOF or closed.
+func (f *filler) streamMultipart(boundary string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		err := writer.SetBoundary(boundary)
+		if err == nil {
+			err = f.writeMultipart(writer, true)
 		}
-		data = body.Bytes()
+		pw.CloseWithError(err)
 	}()
+	return pr
+}
 
-	for field, files := range f.multipart {
+// Returns true when any of the files was added using AddFileReader.
+func (f *filler) hasFileReaders() bool {
+	for _, files := range f.multipart {
 		for _, file := range files {
-			w, e := writer.CreateFormFile(field, file.Name)
-			if e != nil {
-				err = fmt.Errorf("Error creating multipart for field '%s': %w", field, e)
-				return
+			if file.Reader != nil {
+				return true
 			}
-			_, err = w.Write(file.Contents)
-			if err != nil {
-				err = fmt.Errorf("Error writing multipart data for field '%s': %w", field, err)
-				return
+		}
+	}
+	return false
+}
+
+// Returns the length of the multipart body, or -1 when the size of any of the
+// files is unknown. Everything but the file contents is written to a counter.
+func (f *filler) multipartLength(boundary string) (int64, error) {
+	var counter countingWriter
+	for _, files := range f.multipart {
+		for _, file := range files {
+			if file.Size < 0 {
+				return -1, nil
 			}
+			counter += countingWriter(file.Size)
 		}
 	}
+	writer := multipart.NewWriter(&counter)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return 0, fmt.Errorf("Error setting multipart boundary: %w", err)
+	}
+	if err := f.writeMultipart(writer, false); err != nil {
+		return 0, err
+	}
+	return int64(counter), nil
+}
 
-	for field, values := range f.values {
-		for _, value := range values {
-			err := writer.WriteField(field, value)
+// Writes the values and files to the multipart writer and closes it. File
+// contents are skipped when files is false.
+func (f *filler) writeMultipart(writer *multipart.Writer, files bool) (err error) {
+	defer func() {
+		e := writer.Close()
+		if e != nil && err == nil {
+			err = fmt.Errorf("Error closing multipart writer: %s", e)
+		}
+	}()
+
+	for _, field := range f.entries() {
+		if field.file == nil {
+			err = writer.WriteField(f.encodeString(field.name), f.encodeString(field.value))
 			if err != nil {
-				err = fmt.Errorf("Error writing multipart string for field '%s': %w", field, err)
+				return fmt.Errorf("Error writing multipart string for field '%s': %w", field.name, err)
 			}
+			continue
+		}
+		file := field.file
+		w, e := writer.CreateFormFile(field.name, file.Name)
+		if e != nil {
+			return fmt.Errorf("Error creating multipart for field '%s': %w", field.name, e)
+		}
+		if !files {
+			continue
+		}
+		n, e := io.Copy(w, file.open())
+		if e != nil {
+			return fmt.Errorf("Error writing multipart data for field '%s': %w", field.name, e)
+		}
+		if file.Size >= 0 && n != file.Size {
+			return fmt.Errorf("Size of file '%s' for field '%s' is %d, but %d bytes were read", file.Name, field.name, file.Size, n)
 		}
 	}
 
-	return
+	return nil
+}
+
+// Counts the bytes written to it.
+type countingWriter int64
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
 }
 
 func WithContext(ctx context.Context) Option {
@@ -192,6 +629,56 @@ func WithContext(ctx context.Context) Option {
 	}
 }
 
+// Sets the clock used to autofill date and time inputs. By default, AutoFill
+// uses fixed values like AutoFillDate. With a clock, the valid value closest
+// to the current time is used instead. Has to be used before AutoFill.
+func WithClock(now func() time.Time) Option {
+	return func(f *filler) error {
+		f.generator.now = now
+		return nil
+	}
+}
+
+// Uses the cookie jar for the request. Cookies captured by ParseResponse are
+// stored in the jar, and all cookies from the jar matching the request URL
+// are sent.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(f *filler) error {
+		f.jar = jar
+		return nil
+	}
+}
+
+// Seeds the random generator used by AutoFill. DefaultSeed is used by
+// default, so the same values are generated on every run. Has to be used
+// before AutoFill.
+func WithSeed(seed int64) Option {
+	return func(f *filler) error {
+		f.generator.seed(seed)
+		return nil
+	}
+}
+
+// Makes AutoFill ask the provider for values first. See NewFaker for a
+// provider which generates realistic values. Has to be used before AutoFill.
+func WithAutoFill(provider AutoFillProvider) Option {
+	return func(f *filler) error {
+		f.generator.provider = provider
+		return nil
+	}
+}
+
+// Makes AutoFill generate text with non-ASCII characters, including
+// characters which take up two UTF-16 code units like emoji. Useful for
+// testing how an application handles international text. Has to be used
+// before AutoFill.
+func AutoFillUnicode() Option {
+	return func(f *filler) error {
+		f.generator.unicode = true
+		return nil
+	}
+}
+
 // // Adds value to all empty required fields.
 // func (f *filler) AutoFill(defaultValue string) {
 // 	for requiredField, _ := range f.required {
@@ -204,83 +691,234 @@ func WithContext(ctx context.Context) Option {
 // }
 
 // Validates the form (for a plain form request). No need to call this method
-// directly if BuildForm or NewTestRequest are used.
+// directly if BuildForm or NewTestRequest are used. Returns a ValidationError
+// with all fields which had invalid values set, followed by empty required
+// fields in document order. Required fields are not checked when the form
+// is not validated (novalidate or formnovalidate).
 func (f *filler) validateForm() error {
-	for requiredField, _ := range f.required {
-		hasTextValue := f.values.Get(requiredField) != ""
-		hasByteValue := false
-		if f.isMultipart {
-			_, hasByteValue = f.multipart[requiredField]
+	fields := append([]FieldError(nil), f.invalid...)
+	if !f.noValidate {
+		invalid := map[string]struct{}{}
+		for _, field := range f.invalid {
+			invalid[field.Name] = struct{}{}
 		}
-		if !hasTextValue && !hasByteValue {
-			return fmt.Errorf("Required field '%s' has no value", requiredField)
+		for _, field := range f.fields {
+			name := field.input.Name()
+			if !isRequired(field.input) {
+				continue
+			}
+			if _, ok := invalid[name]; ok {
+				continue
+			}
+			hasByteValue := false
+			if f.isMultipart {
+				_, hasByteValue = f.multipart[name]
+			}
+			if !field.hasValue() && !hasByteValue {
+				invalid[name] = struct{}{}
+				fields = append(fields, FieldError{
+					Name:    name,
+					Reason:  ReasonRequired,
+					Message: "value is missing",
+				})
+			}
 		}
 	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fields}
+}
 
-	return nil
+func isRequired(input Input) bool {
+	return input.Required() && !input.Disabled() && !input.ReadOnly()
 }
 
 // Build values for form submission
 func (f *filler) BuildGet() (params string, err error) {
 	err = f.validateForm()
-	params = f.values.Encode()
+	params = f.encode()
 	return params, err
 }
 
 // Build form body for post request
 func (f *filler) BuildPost() (body []byte, err error) {
 	err = f.validateForm()
-	body = []byte(f.values.Encode())
+	body = []byte(f.encode())
 	return
 }
 
+// Fills all empty required fields, in document order. Values are chosen by
+// the AutoFillProvider (see WithAutoFill), falling back to the AutoFill
+// method of each input.
 func AutoFill() Option {
 	return func(f *filler) error {
-		for requiredField, _ := range f.required {
-			value := f.values.Get(requiredField)
-			input := f.form.Inputs[requiredField]
-			if value == "" {
-				add := false
-				for _, value := range input.AutoFill() {
-					var opt Option
-					if input.Type() == InputTypeFile {
-						opt = AddFile(requiredField, "auto-filename", []byte(value))
-					} else {
-						opt = setOrAdd(requiredField, value, add)
-					}
-					if err := opt(f); err != nil {
-						return err
-					}
-					add = true
-				}
+		for i := range f.fields {
+			if !isRequired(f.fields[i].input) {
+				continue
+			}
+			if err := f.autoFill(&f.fields[i]); err != nil {
+				return err
 			}
 		}
 		return nil
 	}
 }
 
+// Same as AutoFill, but fills optional fields too. Disabled, readonly and
+// hidden fields are skipped, as well as fields which already have a value.
+func AutoFillAll() Option {
+	return AutoFillExcept()
+}
+
+// Same as AutoFillAll, but skips the fields with names.
+func AutoFillExcept(names ...string) Option {
+	return func(f *filler) error {
+		except := map[string]struct{}{}
+		for _, name := range names {
+			if f.target(name, false) == nil {
+				return fmt.Errorf("Cannot find input name='%s'", name)
+			}
+			except[name] = struct{}{}
+		}
+		for i := range f.fields {
+			input := f.fields[i].input
+			if _, ok := except[input.Name()]; ok {
+				continue
+			}
+			if input.Disabled() || input.ReadOnly() {
+				continue
+			}
+			if err := f.autoFill(&f.fields[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Fills the field unless it already has a value.
+func (f *filler) autoFill(field *fieldValues) error {
+	input := field.input
+	name := input.Name()
+	if field.hasValue() {
+		return nil
+	}
+	if _, ok := f.multipart[name]; ok {
+		return nil
+	}
+	add := false
+	for _, value := range f.generator.autoFill(input) {
+		var err error
+		if input.Type() == InputTypeFile {
+			err = AddFile(name, "auto-filename", []byte(value))(f)
+		} else {
+			err = f.fill(field, value, add, false)
+		}
+		if err := f.collect(err); err != nil {
+			return err
+		}
+		add = true
+	}
+	return nil
+}
+
 // Adds the submit buttons name=value combination to the form submission.
 // Useful when there are two or more buttons on a form and their values
 // make a difference on how the server's going to process the form data.
+// The formaction, formmethod, formenctype and formnovalidate attributes of
+// the button override the ones of the form.
 func Click(buttonValue string) Option {
+	return click("value", buttonValue, func(b Button) bool {
+		return b.Value == buttonValue
+	})
+}
+
+// Same as Click, but finds the button by its name attribute.
+func ClickByName(name string) Option {
+	return click("name", name, func(b Button) bool {
+		return b.Name == name
+	})
+}
+
+// Same as Click, but finds the button by its id attribute.
+func ClickByID(id string) Option {
+	return click("id", id, func(b Button) bool {
+		return b.ID == id
+	})
+}
+
+// Same as Click, but finds the button by its visible text. Whitespace in the
+// text is collapsed.
+func ClickByText(text string) Option {
+	return click("text", text, func(b Button) bool {
+		return b.Text == text
+	})
+}
+
+// Clicks an <input type="image"> button at coordinates x and y. Browsers
+// submit the coordinates as name.x and name.y instead of the button value.
+func ClickImage(name string, x int, y int) Option {
+	return clickAt("name", name, func(b Button) bool {
+		return b.Type == InputTypeImage && b.Name == name
+	}, x, y)
+}
+
+func click(attr string, value string, matches func(b Button) bool) Option {
+	return clickAt(attr, value, matches, 0, 0)
+}
+
+func clickAt(attr string, value string, matches func(b Button) bool, x int, y int) Option {
 	return func(f *filler) error {
 		if f.clicked == true {
 			return fmt.Errorf("Already clicked on one button")
 		}
 		ok := false
+		disabled := false
 		var b Button
 		for _, button := range f.form.Buttons {
-			if button.Value == buttonValue {
+			if matches(button) {
+				if button.Disabled {
+					disabled = true
+					continue
+				}
 				ok = true
 				b = button
 				break
 			}
 		}
+		if !ok && disabled {
+			return fmt.Errorf("Cannot click disabled button with %s: '%s'", attr, value)
+		}
 		if !ok {
-			return fmt.Errorf("Cannot find button with value: '%s'", buttonValue)
+			return fmt.Errorf("Cannot find button with %s: '%s'", attr, value)
 		}
 		f.clicked = true
-		f.values.Set(b.Name, b.Value)
+		f.submitter = b
+		switch {
+		case b.Type == InputTypeImage:
+			prefix := ""
+			if b.Name != "" {
+				prefix = b.Name + "."
+			}
+			f.values.Set(prefix+"x", itoa(x))
+			f.values.Set(prefix+"y", itoa(y))
+		case b.Name != "":
+			f.values.Set(b.Name, b.Value)
+		}
+		if b.URL != "" {
+			f.url = b.URL
+		}
+		if b.Method != "" {
+			f.method = b.Method
+		}
+		if b.ContentType != "" {
+			f.contentType = b.ContentType
+			f.isMultipart = b.ContentType == ContentTypeMultipart
+		}
+		if b.NoValidate {
+			f.noValidate = true
+		}
 		return nil
 	}
 }
@@ -288,6 +926,11 @@ func Click(buttonValue string) Option {
 // Deletes a field from the form. Useful to remove preselected values
 func Reset(name string) Option {
 	return func(f *filler) error {
+		for i := range f.fields {
+			if f.fields[i].input.Name() == name {
+				f.fields[i].values = nil
+			}
+		}
 		f.values.Del(name)
 		delete(f.multipart, name)
 		return nil
@@ -299,43 +942,182 @@ func Reset(name string) Option {
 // element supports multiple values, like checkboxes or <select multiple>
 // elements.
 func Add(name string, value string) Option {
-	return setOrAdd(name, value, true)
+	return setOrAdd(name, value, true, false)
 }
 
 // Set a name=value pair to the form and replace any set value(s).
 func Set(name string, value string) Option {
-	return setOrAdd(name, value, false)
+	return setOrAdd(name, value, false, false)
+}
+
+// Same as Add, but also adds values to disabled and readonly (including
+// hidden) fields. The value still has to be valid.
+func ForceAdd(name string, value string) Option {
+	return setOrAdd(name, value, true, true)
+}
+
+// Same as Set, but also sets values of disabled and readonly (including
+// hidden) fields. The value still has to be valid.
+func ForceSet(name string, value string) Option {
+	return setOrAdd(name, value, false, true)
 }
 
-func setOrAdd(name string, value string, add bool) Option {
+// Checks the checkbox or radio with the value. Other checked checkboxes stay
+// checked, while other radios in the same group are unchecked.
+func Check(name string, value string) Option {
 	return func(f *filler) error {
-		input, ok := f.form.Inputs[name]
-		if !ok {
-			return fmt.Errorf("Cannot find input name='%s'", name)
+		field, err := f.findCheckable(name)
+		if err != nil {
+			return err
 		}
-		result, ok := input.Fill(value)
-		if !ok {
-			return fmt.Errorf("Value '%s' for input name='%s' is invalid", value, name)
+		if _, isRadio := field.input.(Radio); isRadio {
+			return f.fill(field, value, false, false)
 		}
+		for _, checked := range field.values {
+			if checked == value {
+				return nil
+			}
+		}
+		return f.fill(field, value, true, false)
+	}
+}
 
-		values, ok := f.values[name]
-		hasEmptyValue := ok && len(values) == 1 && values[0] == ""
-
-		if add && !hasEmptyValue {
-			if f.values.Get(name) != "" && !input.Multiple() {
-				return fmt.Errorf("Cannot fill input name='%s'  twice (multiple=false)", name)
+// Unchecks the checkbox with the value. Unchecked checkboxes are not
+// submitted at all.
+func Uncheck(name string, value string) Option {
+	return func(f *filler) error {
+		field, err := f.findCheckable(name)
+		if err != nil {
+			return err
+		}
+		if _, isRadio := field.input.(Radio); isRadio {
+			return fmt.Errorf("Cannot uncheck radio name='%s', check another one instead", name)
+		}
+		if field.input.Disabled() {
+			return &ValidationError{Fields: []FieldError{{
+				Name:    name,
+				Value:   value,
+				Reason:  ReasonReadOnly,
+				Message: "field is disabled",
+			}}}
+		}
+		values := []string{}
+		for _, checked := range field.values {
+			if checked != value {
+				values = append(values, checked)
 			}
-			f.values.Add(name, result)
-		} else {
-			f.values.Set(name, result)
 		}
+		field.values = values
 		return nil
 	}
 }
 
+// Checks or unchecks a single checkbox, like a "remember me" toggle. Use
+// Check and Uncheck for groups of checkboxes sharing a name.
+func SetChecked(name string, checked bool) Option {
+	return func(f *filler) error {
+		field, err := f.findCheckable(name)
+		if err != nil {
+			return err
+		}
+		options := field.input.Options()
+		if _, isRadio := field.input.(Radio); isRadio || len(options) != 1 {
+			return fmt.Errorf("Input name='%s' is not a single checkbox", name)
+		}
+		if checked {
+			return Check(name, options[0])(f)
+		}
+		return Uncheck(name, options[0])(f)
+	}
+}
+
+// Selects the option of a select element by its visible text (or label
+// attribute) instead of its value. Like Add, the option is added to already
+// selected options of <select multiple> elements.
+func SelectByLabel(name string, label string) Option {
+	return func(f *filler) error {
+		if f.target(name, false) == nil {
+			return fmt.Errorf("Cannot find input name='%s'", name)
+		}
+		field := f.findField(name, func(input Input) bool {
+			_, ok := input.(Select)
+			return ok
+		})
+		if field == nil {
+			return fmt.Errorf("Input name='%s' is not a select", name)
+		}
+		sel := field.input.(Select)
+		for i, l := range sel.Labels() {
+			if l == label {
+				return f.fill(field, sel.Options()[i], sel.Multiple(), false)
+			}
+		}
+		return fmt.Errorf("Cannot find option with label '%s' in select name='%s'", label, name)
+	}
+}
+
+func (f *filler) findCheckable(name string) (*fieldValues, error) {
+	if f.target(name, false) == nil {
+		return nil, fmt.Errorf("Cannot find input name='%s'", name)
+	}
+	field := f.findField(name, isCheckable)
+	if field == nil {
+		return nil, fmt.Errorf("Input name='%s' is not a checkbox or radio", name)
+	}
+	return field, nil
+}
+
+func setOrAdd(name string, value string, add bool, force bool) Option {
+	return func(f *filler) error {
+		field := f.target(name, force)
+		if field == nil {
+			return fmt.Errorf("Cannot find input name='%s'", name)
+		}
+		return f.fill(field, value, add, force)
+	}
+}
+
+// Validates the value and sets it (or adds it) to the values of the field.
+func (f *filler) fill(field *fieldValues, value string, add bool, force bool) error {
+	input := field.input
+	name := input.Name()
+	var result string
+	var fieldErr *FieldError
+	if !force && input.Disabled() {
+		fieldErr = newFieldError(ReasonReadOnly, "field is disabled")
+	} else if !force && input.ReadOnly() {
+		fieldErr = newFieldError(ReasonReadOnly, "field is readonly")
+	} else if c, ok := input.(checker); ok {
+		result, fieldErr = c.check(value)
+	} else if result, ok = input.Fill(value); !ok {
+		fieldErr = newFieldError(ReasonType, "value is not accepted")
+	}
+	if fieldErr != nil {
+		fieldErr.Name = name
+		fieldErr.Value = value
+		return &ValidationError{Fields: []FieldError{*fieldErr}}
+	}
+
+	hasEmptyValue := len(field.values) == 1 && field.values[0] == ""
+
+	if add && !hasEmptyValue {
+		if field.hasValue() && !input.Multiple() {
+			return fmt.Errorf("Cannot fill input name='%s'  twice (multiple=false)", name)
+		}
+		field.values = append(field.values, result)
+	} else {
+		field.values = []string{result}
+	}
+	return nil
+}
+
 // Set a value without validation
 func UnsafeSet(name string, value string) Option {
 	return func(f *filler) error {
+		if field := f.target(name, false); field != nil {
+			field.values = []string{value}
+			return nil
+		}
 		f.values.Set(name, value)
 		return nil
 	}
@@ -344,22 +1126,59 @@ func UnsafeSet(name string, value string) Option {
 // Fill data for multipart request
 func AddFile(fieldname string, filename string, contents []byte) Option {
 	return func(f *filler) error {
-		input, ok := f.form.Inputs[fieldname]
-		if !ok {
-			return fmt.Errorf("Cannot find input fieldname='%s'", fieldname)
-		}
-		_, ok = input.(FileInput)
-		if !ok {
-			return fmt.Errorf("Cannot fill bytes - input fieldname='%s' is not a file input", fieldname)
-		}
-		filesArray, ok := f.multipart[fieldname]
-		if !ok {
-			filesArray = []multipartFile{}
-		}
-		f.multipart[fieldname] = append(filesArray, multipartFile{
-			Name:     filename,
+		return f.addFile(fieldname, multipartFile{
 			Contents: contents,
+			Name:     filename,
+			Size:     int64(len(contents)),
 		})
-		return nil
 	}
 }
+
+// Fill data for multipart request from a reader, which is read while the
+// request body is being sent, so large files do not need to be kept in
+// memory. The size is the number of bytes which will be read, or -1 when it
+// is unknown. When the sizes of all files are known, the Content-Length of
+// the request is set.
+//
+// The request body is written by a goroutine while it is being read, so it
+// has to be read until EOF or closed. Handlers served by SubmitTo or a
+// Session do not have to read it, because the body is closed afterwards.
+//
+// The reader can only be read once, so the option should not be reused.
+// Unlike with AddFile, the request body cannot be sent again, so 307 and 308
+// redirects cannot be followed.
+func AddFileReader(fieldname string, filename string, r io.Reader, size int64) Option {
+	return func(f *filler) error {
+		if size < 0 {
+			size = -1
+		}
+		return f.addFile(fieldname, multipartFile{
+			Reader: r,
+			Name:   filename,
+			Size:   size,
+		})
+	}
+}
+
+func (f *filler) addFile(fieldname string, file multipartFile) error {
+	if f.target(fieldname, false) == nil {
+		return fmt.Errorf("Cannot find input fieldname='%s'", fieldname)
+	}
+	field := f.findField(fieldname, func(input Input) bool {
+		_, ok := input.(FileInput)
+		return ok
+	})
+	if field == nil {
+		return fmt.Errorf("Cannot fill bytes - input fieldname='%s' is not a file input", fieldname)
+	}
+	if field.input.Disabled() {
+		return &ValidationError{Fields: []FieldError{{
+			Name:    fieldname,
+			Value:   file.Name,
+			Reason:  ReasonReadOnly,
+			Message: "field is disabled",
+		}}}
+	}
+	f.multipart[fieldname] = append(f.multipart[fieldname], file)
+	return nil
+}
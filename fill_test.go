@@ -3,15 +3,21 @@ package gosubmit_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"reflect"
 	"regexp"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	. "github.com/jeremija/gosubmit"
 )
@@ -216,6 +222,115 @@ func TestNewTestRequest_multipart(t *testing.T) {
 	}
 }
 
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestAddFileReader(t *testing.T) {
+	form := Parse(strings.NewReader(`<form method="post" action="/upload" enctype="multipart/form-data">
+<input name="title" value="Large">
+<input type="file" name="upload">
+</form>`)).FirstForm()
+
+	const size = 8 << 20
+	const limit = 1 << 20
+	r, err := form.NewTestRequest(
+		AddFileReader("upload", "large.bin", io.LimitReader(zeroReader{}, size), size),
+	)
+	if err != nil {
+		t.Fatalf("Error creating test request: %s", err)
+	}
+	if r.ContentLength <= size {
+		t.Fatalf("Expected content length to be set, but was %d", r.ContentLength)
+	}
+	n, err := io.Copy(ioutil.Discard, r.Body)
+	if err != nil {
+		t.Fatalf("Error reading body: %s", err)
+	}
+	if n != r.ContentLength {
+		t.Errorf("Expected %d bytes to be read, but got %d", r.ContentLength, n)
+	}
+
+	r, err = form.NewTestRequest(
+		AddFileReader("upload", "large.bin", io.LimitReader(zeroReader{}, size), -1),
+	)
+	if err != nil {
+		t.Fatalf("Error creating test request: %s", err)
+	}
+	if r.ContentLength != -1 {
+		t.Errorf("Expected content length to be unknown, but was %d", r.ContentLength)
+	}
+	w := httptest.NewRecorder()
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	if err := r.ParseMultipartForm(defaultMaxMemory); err == nil {
+		t.Error("Expected an error when the body exceeds the limit")
+	}
+	r.Body.Close()
+
+	r, err = form.NewTestRequest(
+		AddFileReader("upload", "small.txt", strings.NewReader("hello"), 10),
+	)
+	if err != nil {
+		t.Fatalf("Error creating test request: %s", err)
+	}
+	_, err = ioutil.ReadAll(r.Body)
+	re := regexp.MustCompile("Size of file 'small.txt' for field 'upload' is 10, but 5 bytes were read")
+	if err == nil || !re.MatchString(err.Error()) {
+		t.Errorf("Expected error to match '%s', but was '%v'", re, err)
+	}
+
+	r, err = form.NewTestRequest(
+		AddFileReader("upload", "small.txt", strings.NewReader("hello"), 5),
+	)
+	if err != nil {
+		t.Fatalf("Error creating test request: %s", err)
+	}
+	if err := r.ParseMultipartForm(defaultMaxMemory); err != nil {
+		t.Fatalf("Error parsing multipart form: %s", err)
+	}
+	file, header, err := r.FormFile("upload")
+	if err != nil {
+		t.Fatalf("Cannot read upload: %s", err)
+	}
+	defer file.Close()
+	data, _ := ioutil.ReadAll(file)
+	if header.Filename != "small.txt" || string(data) != "hello" || r.FormValue("title") != "Large" {
+		t.Errorf("Unexpected upload: %s %s %s", header.Filename, data, r.FormValue("title"))
+	}
+}
+
+func TestAddFile_buffered(t *testing.T) {
+	form := Parse(strings.NewReader(`<form method="post" action="http://example.com/upload" enctype="multipart/form-data">
+<input type="file" name="upload">
+</form>`)).FirstForm()
+	forbidden := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		r, err := form.NewTestRequest(AddFile("upload", "a.txt", []byte("hello")))
+		if err != nil {
+			t.Fatalf("Error creating test request: %s", err)
+		}
+		forbidden.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	waitForGoroutines(t, before)
+
+	r, err := form.NewRequest(AddFile("upload", "a.txt", []byte("hello")))
+	if err != nil {
+		t.Fatalf("Error creating request: %s", err)
+	}
+	if r.GetBody == nil || r.ContentLength <= 0 {
+		t.Errorf("Expected the body to be buffered, but got length %d", r.ContentLength)
+	}
+}
+
 func TestAutoFill(t *testing.T) {
 	f := mustOpen(t, "./forms/big-empty.html")
 	defer f.Close()
@@ -224,7 +339,7 @@ func TestAutoFill(t *testing.T) {
 
 	r, err := form.NewTestRequest(
 		AutoFill(),
-		// autofill won't fill up fields with patterns
+		// replaces the value generated from the pattern
 		Set("firstName", "John"),
 		Click("Save 1"),
 	)
@@ -242,6 +357,7 @@ func TestAutoFill(t *testing.T) {
 	randomCaptcha := r.FormValue("captcha")
 
 	expectedForm := url.Values{
+		"sel1":      []string{"1"},
 		"sel2":      []string{"4", "5", "6"},
 		"chk":       []string{"subscribe-mail", "subscribe-phone"},
 		"contact":   []string{"call"},
@@ -401,3 +517,1157 @@ func TestGetParams_ok(t *testing.T) {
 		t.Errorf("Expected params to be '%s' but was '%s'", expected, params)
 	}
 }
+
+func TestNewTestRequest_form_attribute(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<html>
+<body>
+<input type="text" name="before" form="checkout" value="b">
+<form id="checkout" method="post" action="/checkout">
+  <input type="text" name="address" required>
+  <input type="text" name="search" form="search" value="s">
+</form>
+<form id="search" action="/search"></form>
+<footer>
+  <input type="hidden" name="after" form="checkout" value="a">
+  <input type="text" name="orphan" form="missing" value="o">
+  <button type="submit" form="checkout" name="action" value="draft"
+    formaction="/checkout/draft" formmethod="get">Save draft</button>
+  <button type="submit" form="checkout" name="action" value="publish">Publish</button>
+</footer>
+</body>
+</html>`))
+
+	form := doc.FormBySelector("#checkout")
+	if err := form.Err(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if size := len(form.Buttons); size != 2 {
+		t.Fatalf("Expected to find 2 buttons, but got %d", size)
+	}
+
+	r, err := form.NewTestRequest(
+		Set("address", "Street 1"),
+		Click("publish"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if r.Method != http.MethodPost || r.URL.Path != "/checkout" {
+		t.Errorf("Expected POST /checkout, but got %s %s", r.Method, r.URL.Path)
+	}
+	r.ParseForm()
+	expected := url.Values{
+		"before":  []string{"b"},
+		"address": []string{"Street 1"},
+		"after":   []string{"a"},
+		"action":  []string{"publish"},
+	}
+	if !reflect.DeepEqual(expected, r.PostForm) {
+		t.Error("Expected form to be", expected, "but was", r.PostForm)
+	}
+
+	r, err = form.NewTestRequest(
+		Set("address", "Street 1"),
+		Click("draft"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if r.Method != http.MethodGet || r.URL.Path != "/checkout/draft" {
+		t.Errorf("Expected GET /checkout/draft, but got %s %s", r.Method, r.URL.Path)
+	}
+	if action := r.URL.Query().Get("action"); action != "draft" {
+		t.Errorf("Expected action to be draft, but was %s", action)
+	}
+
+	search := doc.FormBySelector("#search")
+	if _, ok := search.Inputs["search"]; !ok || len(search.Inputs) != 1 {
+		t.Errorf("Expected search form to contain only the search input, but got %v", search.Inputs)
+	}
+}
+
+func TestPostParams_field_order(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post">
+  <input type="text" name="zeta" value="z">
+  <input type="text" name="items[]" value="first">
+  <input type="hidden" name="agree" value="0">
+  <input type="checkbox" name="agree" value="1" checked>
+  <input type="text" name="alpha" value="a">
+  <input type="text" name="items[]" value="second">
+  <input type="text" value="no name">
+  <button type="submit" name="action" value="save">Save</button>
+</form>`))
+	form := doc.FirstForm()
+
+	if size := len(form.Fields); size != 6 {
+		t.Fatalf("Expected 6 fields, but got %d", size)
+	}
+	if _, ok := form.Fields[2].(HiddenInput); !ok {
+		t.Errorf("Expected third field to be a hidden input, but was %T", form.Fields[2])
+	}
+	if _, ok := form.Inputs["agree"].(Checkbox); !ok {
+		t.Errorf("Expected agree to be indexed as a checkbox, but was %T", form.Inputs["agree"])
+	}
+
+	for i := 0; i < 10; i++ {
+		body, err := form.PostParams(Click("save"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		expected := "zeta=z&items%5B%5D=first&agree=0&agree=1&alpha=a&items%5B%5D=second&action=save"
+		if string(body) != expected {
+			t.Fatalf("Expected body to be:\n%s\nbut was:\n%s", expected, body)
+		}
+	}
+
+	boundary, data, err := form.MultipartParams()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	reader := multipart.NewReader(bytes.NewReader(data), boundary)
+	var names []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		names = append(names, part.FormName())
+	}
+	expectedNames := []string{"zeta", "items[]", "agree", "agree", "alpha", "items[]"}
+	if !reflect.DeepEqual(expectedNames, names) {
+		t.Errorf("Expected multipart fields to be %v, but were %v", expectedNames, names)
+	}
+}
+
+func TestPostParams_interleaved(t *testing.T) {
+	form := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post">
+  <input name="items[][name]" value="a">
+  <input name="items[][qty]" value="1">
+  <input type="checkbox" name="tags[]" value="x">
+  <button name="action" value="first">First</button>
+  <input name="items[][name]" value="b">
+  <input name="items[][qty]" value="2">
+  <input type="checkbox" name="tags[]" value="y">
+  <button name="action" value="second">Second</button>
+</form>`)).FirstForm()
+
+	body, err := form.PostParams(
+		Check("tags[]", "y"),
+		Check("tags[]", "x"),
+		Click("first"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := "items%5B%5D%5Bname%5D=a&items%5B%5D%5Bqty%5D=1&tags%5B%5D=x&action=first" +
+		"&items%5B%5D%5Bname%5D=b&items%5B%5D%5Bqty%5D=2&tags%5B%5D=y"
+	if string(body) != expected {
+		t.Errorf("Expected body to be:\n%s\nbut was:\n%s", expected, body)
+	}
+}
+
+func TestSet_same_name(t *testing.T) {
+	for _, test := range []struct {
+		html     string
+		expected string
+	}{
+		{
+			`<input type="text" name="x" value="t"><input type="hidden" name="x" value="h">`,
+			"x=new&x=h",
+		},
+		{
+			`<input type="hidden" name="x" value="h"><input type="text" name="x" value="t">`,
+			"x=h&x=new",
+		},
+	} {
+		form := Parse(strings.NewReader(`<!DOCTYPE html><form method="post">` +
+			test.html + `</form>`)).FirstForm()
+
+		body, err := form.PostParams(Set("x", "new"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if string(body) != test.expected {
+			t.Errorf("Expected body to be %s, but was %s", test.expected, body)
+		}
+
+		body, err = form.PostParams(ForceSet("x", "forced"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		expected := strings.Replace(strings.Replace(test.expected, "new", "t", 1), "h", "forced", 1)
+		if string(body) != expected {
+			t.Errorf("Expected body to be %s, but was %s", expected, body)
+		}
+	}
+}
+
+func TestClick_button_overrides(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/posts/publish">
+  <input type="text" name="title" required>
+  <button id="draft" name="state" value="draft" formaction="/posts/draft"
+    formenctype="multipart/form-data" formnovalidate>
+    Save   draft
+  </button>
+  <button type="submit" name="state" value="published">Publish</button>
+  <button type="button" name="preview">Preview</button>
+  <input type="submit" value="Plain">
+</form>`))
+	form := doc.FirstForm()
+
+	if size := len(form.Buttons); size != 3 {
+		t.Fatalf("Expected 3 submit buttons, but got %d", size)
+	}
+
+	for _, opt := range []Option{
+		ClickByID("draft"),
+		ClickByText("Save draft"),
+		// the first button with the name is used
+		ClickByName("state"),
+	} {
+		r, err := form.NewTestRequest(opt)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if r.URL.Path != "/posts/draft" {
+			t.Errorf("Expected url to be /posts/draft, but was %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(defaultMaxMemory); err != nil {
+			t.Fatalf("Error parsing multipart form: %s", err)
+		}
+		if state := r.PostFormValue("state"); state != "draft" {
+			t.Errorf("Expected state to be draft, but was %s", state)
+		}
+	}
+
+	_, err := form.NewTestRequest(ClickByText("Publish"))
+	expected := "Required field 'title' has no value"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', but got %s", expected, err)
+	}
+
+	r, err := form.NewTestRequest(Set("title", "Hello"), ClickByText("Plain"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	r.ParseForm()
+	expectedForm := url.Values{"title": []string{"Hello"}}
+	if !reflect.DeepEqual(expectedForm, r.PostForm) {
+		t.Error("Expected form to be", expectedForm, "but was", r.PostForm)
+	}
+
+	_, err = form.NewTestRequest(ClickByText("Preview"))
+	expected = "Cannot find button with text: 'Preview'"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', but got %s", expected, err)
+	}
+}
+
+func TestClickImage(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/admin">
+  <input type="hidden" name="id" value="7">
+  <input type="image" name="delete" src="delete.gif" alt="Delete" formaction="/admin/delete">
+  <input type="image" src="save.gif" alt="Save">
+</form>`))
+	form := doc.FirstForm()
+
+	if size := len(form.Inputs); size != 1 {
+		t.Errorf("Expected image buttons not to be inputs, but got %d inputs", size)
+	}
+
+	body, err := form.PostParams(ClickImage("delete", 10, 15))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if expected := "id=7&delete.x=10&delete.y=15"; string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+
+	r, err := form.NewTestRequest(ClickImage("delete", 1, 2))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if r.URL.Path != "/admin/delete" {
+		t.Errorf("Expected url to be /admin/delete, but was %s", r.URL.Path)
+	}
+
+	body, err = form.PostParams(ClickByText("Save"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if expected := "id=7&x=0&y=0"; string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+
+	_, err = form.PostParams(ClickImage("missing", 0, 0))
+	expected := "Cannot find button with name: 'missing'"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', but got %s", expected, err)
+	}
+}
+
+func TestAutoFill_html5_types(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/book">
+  <input type="time" name="time" required>
+  <input type="datetime-local" name="arrival" required>
+  <input type="month" name="month" required>
+  <input type="week" name="week" required>
+  <input type="color" name="color" value="invalid">
+  <input type="range" name="guests" min="1" max="10">
+  <input type="range" name="volume" value="200">
+  <input type="tel" name="phone" required>
+  <input type="search" name="q">
+  <input type="password" name="password" required>
+</form>`))
+	form := doc.FirstForm()
+
+	r, err := form.NewTestRequest(AutoFill())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	r.ParseForm()
+	expected := url.Values{
+		"time":     []string{AutoFillTime},
+		"arrival":  []string{AutoFillDateTimeLocal},
+		"month":    []string{AutoFillMonth},
+		"week":     []string{AutoFillWeek},
+		"color":    []string{"#000000"},
+		"guests":   []string{"6"},
+		"volume":   []string{"100"},
+		"phone":    []string{AutoFillTel},
+		"q":        []string{""},
+		"password": []string{"password-autofill"},
+	}
+	if !reflect.DeepEqual(expected, r.PostForm) {
+		t.Error("Expected form to be:\n", expected, "\nbut was:\n", r.PostForm)
+	}
+
+	_, err = form.PostParams(Set("guests", "11"), AutoFill())
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !validationErr.Has("guests", ReasonMax) {
+		t.Errorf("Expected guests to exceed max, but got %s", err)
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/signup">
+  <input type="text" name="user" pattern="[a-z]+" required>
+  <input type="text" name="nick" minlength="3" maxlength="5">
+  <input type="email" name="email" required>
+  <input type="number" name="age" min="18" required>
+  <input type="hidden" name="token" value="abc">
+  <select name="plan"><option value="free">Free</option></select>
+  <input type="text" name="bio" required>
+</form>`))
+	form := doc.FirstForm()
+
+	err := form.Validate(
+		Set("user", "Joe"),
+		Set("nick", "jo"),
+		Set("email", "joe"),
+		Set("age", "17.5"),
+		Set("token", "xyz"),
+		Set("plan", "pro"),
+	)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, but got %s", err)
+	}
+	expected := []FieldError{
+		{"user", "Joe", ReasonPattern, "value does not match pattern"},
+		{"nick", "jo", ReasonMinLength, "value is shorter than minlength 3"},
+		{"email", "joe", ReasonType, "value is not an email address"},
+		{"age", "17.5", ReasonMin, "value is less than min '18'"},
+		{"token", "xyz", ReasonReadOnly, "field is readonly"},
+		{"plan", "pro", ReasonNotAnOption, `value is not one of the options ["free"]`},
+		{"bio", "", ReasonRequired, "value is missing"},
+	}
+	if !reflect.DeepEqual(expected, validationErr.Fields) {
+		t.Errorf("Expected fields to be:\n%v\nbut were:\n%v", expected, validationErr.Fields)
+	}
+	if validationErr.Has("user", ReasonRequired) {
+		t.Error("Expected invalid required field not to be reported as missing")
+	}
+
+	_, err = form.PostParams(Set("age", "20"))
+	expectedErr := "Required field 'user' has no value; Required field 'email' has no value; Required field 'bio' has no value"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected error '%s', but got %s", expectedErr, err)
+	}
+
+	if err := form.Validate(Set("user", "joe"), Set("age", "21"), AutoFill()); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func TestDateInput_constraints(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/reserve">
+  <input type="date" name="arrival" min="2030-01-01" max="2030-12-31" required>
+  <input type="date" name="departure" min="2030-01-01" step="7" required>
+  <input type="time" name="time" min="09:00" max="17:00" step="1800" required>
+  <input type="month" name="month" max="2000-06" required>
+</form>`))
+	form := doc.FirstForm()
+
+	for _, test := range []struct {
+		name   string
+		value  string
+		reason Reason
+		err    string
+	}{
+		{"arrival", "2029-12-31", ReasonMin, "Value '2029-12-31' for input name='arrival' is invalid: value is before min '2030-01-01'"},
+		{"arrival", "2031-01-01", ReasonMax, "Value '2031-01-01' for input name='arrival' is invalid: value is after max '2030-12-31'"},
+		{"arrival", "2030-02-30", ReasonType, "Value '2030-02-30' for input name='arrival' is invalid: value has invalid format"},
+		{"departure", "2030-01-09", ReasonStep, "Value '2030-01-09' for input name='departure' is invalid: value does not match step"},
+		{"time", "09:15", ReasonStep, "Value '09:15' for input name='time' is invalid: value does not match step"},
+		{"time", "17:30", ReasonMax, "Value '17:30' for input name='time' is invalid: value is after max '17:00'"},
+	} {
+		err := form.Validate(Set(test.name, test.value))
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || !validationErr.Has(test.name, test.reason) {
+			t.Errorf("Expected %s error for %s, but got %s", test.reason, test.name, err)
+			continue
+		}
+		if fields := validationErr.Field(test.name); fields[0].Error() != test.err {
+			t.Errorf("Expected error '%s', but got %s", test.err, fields[0].Error())
+		}
+	}
+
+	body, err := form.PostParams(AutoFill())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := "arrival=2030-01-01&departure=2030-01-01&time=15%3A30&month=2000-06"
+	if string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+
+	now := func() time.Time {
+		return time.Date(2030, time.March, 14, 10, 10, 0, 0, time.UTC)
+	}
+	body, err = form.PostParams(WithClock(now), AutoFill())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected = "arrival=2030-03-14&departure=2030-03-19&time=10%3A30&month=2000-06"
+	if string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+}
+
+func TestTimeInput_overnight(t *testing.T) {
+	form := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/reserve">
+  <input type="time" name="time" min="22:00" max="06:00" step="1800" required>
+</form>`)).FirstForm()
+
+	for _, value := range []string{"22:00", "23:30", "00:00", "06:00"} {
+		if err := form.Validate(Set("time", value)); err != nil {
+			t.Errorf("Expected %s to be valid, but got %s", value, err)
+		}
+	}
+
+	for _, test := range []struct {
+		value  string
+		reason Reason
+	}{
+		{"12:00", ReasonMax},
+		{"06:30", ReasonMax},
+		{"23:15", ReasonStep},
+		{"01:15", ReasonStep},
+	} {
+		err := form.Validate(Set("time", test.value))
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || !validationErr.Has("time", test.reason) {
+			t.Errorf("Expected %s error for %s, but got %v", test.reason, test.value, err)
+		}
+	}
+	err := form.Validate(Set("time", "12:00"))
+	expectedErr := "Value '12:00' for input name='time' is invalid: value is not between min '22:00' and max '06:00'"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected error '%s', but got '%v'", expectedErr, err)
+	}
+
+	for clock, expected := range map[int]string{3: "03%3A00", 12: "22%3A00", 23: "23%3A00"} {
+		hour := clock
+		now := func() time.Time {
+			return time.Date(2030, time.March, 14, hour, 0, 0, 0, time.UTC)
+		}
+		body, err := form.PostParams(WithClock(now), AutoFill())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if string(body) != "time="+expected {
+			t.Errorf("Expected time=%s at %d:00, but got %s", expected, hour, body)
+		}
+	}
+}
+
+func TestDateInput_farDates(t *testing.T) {
+	form := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/reserve">
+  <input type="date" name="d">
+  <input type="date" name="e" min="1600-01-01" max="9999-12-31">
+  <input type="week" name="w">
+  <input type="datetime-local" name="dt">
+</form>`)).FirstForm()
+
+	body, err := form.PostParams(
+		Set("d", "2500-01-01"),
+		Set("e", "1650-06-01"),
+		Set("w", "2300-W10"),
+		Set("dt", "1500-03-01T10:30"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := "d=2500-01-01&e=1650-06-01&w=2300-W10&dt=1500-03-01T10%3A30"
+	if string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+
+	err = form.Validate(Set("e", "1599-12-31"))
+	expectedErr := "Value '1599-12-31' for input name='e' is invalid: value is before min '1600-01-01'"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected error '%s', but got '%v'", expectedErr, err)
+	}
+}
+
+func TestDisabledAndReadOnly(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/profile">
+  <input type="text" name="user" value="joe" readonly required>
+  <input type="text" name="old" value="x" disabled required>
+  <input type="checkbox" name="tags" value="a" checked disabled>
+  <input type="checkbox" name="tags" value="b" checked>
+  <fieldset disabled>
+    <legend><input type="text" name="legend" value="l"></legend>
+    <input type="text" name="inner" value="i" required>
+    <button name="save" value="1">Save</button>
+  </fieldset>
+  <input type="hidden" name="token" value="abc">
+  <button name="send" value="1">Send</button>
+</form>`))
+	form := doc.FirstForm()
+
+	if !form.Inputs["user"].ReadOnly() || form.Inputs["user"].Disabled() {
+		t.Error("Expected user to be readonly")
+	}
+	for _, name := range []string{"old", "inner"} {
+		if !form.Inputs[name].Disabled() {
+			t.Errorf("Expected %s to be disabled", name)
+		}
+	}
+	if form.Inputs["legend"].Disabled() {
+		t.Error("Expected input in the first legend not to be disabled")
+	}
+	if !form.Buttons[0].Disabled || form.Buttons[1].Disabled {
+		t.Error("Expected only the save button to be disabled")
+	}
+
+	body, err := form.PostParams()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := "user=joe&tags=b&legend=l&token=abc"
+	if string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+
+	err = form.Validate(
+		Set("user", "ann"),
+		Set("old", "y"),
+		Add("tags", "a"),
+		Set("token", "xyz"),
+	)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, but got %s", err)
+	}
+	for _, name := range []string{"user", "old", "token"} {
+		if !validationErr.Has(name, ReasonReadOnly) {
+			t.Errorf("Expected %s to be rejected as readonly, but got %s", name, err)
+		}
+	}
+	if !validationErr.Has("tags", ReasonNotAnOption) {
+		t.Errorf("Expected disabled checkbox not to be an option, but got %s", err)
+	}
+
+	if err := form.Validate(ClickByName("save")); err == nil {
+		t.Error("Expected an error when clicking a disabled button")
+	}
+
+	body, err = form.PostParams(
+		ForceSet("user", "ann"),
+		ForceSet("old", "y"),
+		ForceSet("token", "xyz"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected = "user=ann&old=y&tags=b&legend=l&token=xyz"
+	if string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+}
+
+func TestCheckAndUncheck(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/settings">
+  <input type="checkbox" name="remember">
+  <input type="checkbox" name="flags" value="a" checked>
+  <input type="checkbox" name="flags" value="b">
+  <input type="radio" name="plan" value="free" checked>
+  <input type="radio" name="plan">
+  <input type="text" name="nick">
+</form>`))
+	form := doc.FirstForm()
+
+	if options := form.Inputs["remember"].Options(); !reflect.DeepEqual(options, []string{"on"}) {
+		t.Errorf("Expected default checkbox value 'on', but got %v", options)
+	}
+
+	body, err := form.PostParams()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if expected := "flags=a&plan=free&nick="; string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+
+	body, err = form.PostParams(
+		SetChecked("remember", true),
+		Check("flags", "b"),
+		Check("flags", "b"),
+		Uncheck("flags", "a"),
+		Check("plan", "on"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if expected := "remember=on&flags=b&plan=on&nick="; string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+
+	body, err = form.PostParams(SetChecked("remember", true), SetChecked("remember", false), Uncheck("flags", "a"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if expected := "plan=free&nick="; string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+
+	for _, test := range []struct {
+		opt Option
+		err string
+	}{
+		{SetChecked("flags", true), "Input name='flags' is not a single checkbox"},
+		{Uncheck("plan", "free"), "Cannot uncheck radio name='plan', check another one instead"},
+		{Check("nick", "x"), "Input name='nick' is not a checkbox or radio"},
+		{Check("missing", "x"), "Cannot find input name='missing'"},
+		{Check("flags", "c"), `Value 'c' for input name='flags' is invalid: value is not one of the options ["a" "b"]`},
+	} {
+		if err := form.Validate(test.opt); err == nil || err.Error() != test.err {
+			t.Errorf("Expected error '%s', but got %s", test.err, err)
+		}
+	}
+}
+
+func TestSelect_options(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/order">
+  <select name="size">
+    <option disabled>Choose</option>
+    <optgroup label="Small">
+      <option value="xs">Extra small</option>
+      <option selected>S</option>
+    </optgroup>
+    <optgroup label="Large" disabled>
+      <option value="xl">Extra large</option>
+    </optgroup>
+    <option value="m" label="Medium" selected>M</option>
+  </select>
+  <select name="color">
+    <option>  Dark   red </option>
+    <option>Blue</option>
+  </select>
+  <select name="extras" multiple>
+    <option value="1">Gift wrap</option>
+    <option value="2">Card</option>
+  </select>
+</form>`))
+	form := doc.FirstForm()
+
+	size := form.Inputs["size"].(Select)
+	if expected := []string{"xs", "S", "m"}; !reflect.DeepEqual(expected, size.Options()) {
+		t.Errorf("Expected options to be %v, but got %v", expected, size.Options())
+	}
+	if expected := []string{"Extra small", "S", "Medium"}; !reflect.DeepEqual(expected, size.Labels()) {
+		t.Errorf("Expected labels to be %v, but got %v", expected, size.Labels())
+	}
+
+	body, err := form.PostParams()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if expected := "size=m&color=Dark+red"; string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+
+	body, err = form.PostParams(
+		SelectByLabel("size", "Extra small"),
+		SelectByLabel("color", "Blue"),
+		SelectByLabel("extras", "Gift wrap"),
+		SelectByLabel("extras", "Card"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if expected := "size=xs&color=Blue&extras=1&extras=2"; string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+
+	err = form.Validate(SelectByLabel("size", "Extra large"))
+	expectedErr := "Cannot find option with label 'Extra large' in select name='size'"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected error '%s', but got %s", expectedErr, err)
+	}
+}
+
+func TestTextArea(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/comment">
+  <textarea name="comment" placeholder="Say something" minlength="3" maxlength="5" wrap="hard" required></textarea>
+  <textarea name="notes">
+line 1
+line 2</textarea>
+</form>`))
+	form := doc.FirstForm()
+
+	comment, ok := form.Inputs["comment"].(TextArea)
+	if !ok {
+		t.Fatalf("Expected comment to be a TextArea, but was %T", form.Inputs["comment"])
+	}
+	if comment.Placeholder() != "Say something" || comment.Wrap() != "hard" {
+		t.Errorf("Unexpected placeholder '%s' or wrap '%s'", comment.Placeholder(), comment.Wrap())
+	}
+
+	err := form.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !validationErr.Has("comment", ReasonRequired) {
+		t.Errorf("Expected comment to be required, but got %s", err)
+	}
+
+	for _, test := range []struct {
+		value  string
+		reason Reason
+	}{
+		{"ab", ReasonMinLength},
+		{"a\r\nb", ""},
+		{"ćčžšđ", ""},
+		{"😀😀😀", ReasonMaxLength},
+		{"a\nb\nc", ""},
+	} {
+		err := form.Validate(Set("comment", test.value))
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("Unexpected error for '%s': %s", test.value, err)
+			}
+			continue
+		}
+		if !errors.As(err, &validationErr) || !validationErr.Has("comment", test.reason) {
+			t.Errorf("Expected %s error for '%s', but got %s", test.reason, test.value, err)
+		}
+	}
+
+	body, err := form.PostParams(Set("comment", "a\nb\rc"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := "comment=a%0D%0Ab%0D%0Ac&notes=line+1%0D%0Aline+2"
+	if string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+}
+
+func TestTextInput_lengthAttributes(t *testing.T) {
+	form := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/profile">
+  <input type="text" name="a" maxlength="0">
+  <input type="text" name="b" minlength="3">
+  <input type="text" name="c" pattern="[0-9]+">
+  <input type="text" name="d" maxlength="-1" minlength="abc">
+  <textarea name="e" minlength="3"></textarea>
+</form>`)).FirstForm()
+
+	err := form.Validate(Set("a", "xyz"))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !validationErr.Has("a", ReasonMaxLength) {
+		t.Errorf("Expected maxlength=0 to be a bound, but got %v", err)
+	}
+
+	// constraints only apply to values which are not empty
+	if err := form.Validate(Set("b", ""), Set("c", ""), Set("e", "")); err != nil {
+		t.Errorf("Expected empty optional fields to be valid, but got %s", err)
+	}
+
+	if err := form.Validate(Set("d", strings.Repeat("x", 100))); err != nil {
+		t.Errorf("Expected invalid lengths to be ignored, but got %s", err)
+	}
+}
+
+func TestAutoFillUnicode(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/profile">
+  <input type="text" name="name" required>
+  <input type="text" name="city" maxlength="8" required>
+  <input type="email" name="email" required>
+</form>`))
+
+	r, err := doc.FirstForm().NewTestRequest(AutoFillUnicode(), AutoFill())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := r.ParseForm(); err != nil {
+		t.Fatalf("Error parsing form: %s", err)
+	}
+	for _, name := range []string{"name", "city"} {
+		value := r.PostForm.Get(name)
+		if utf8.RuneCountInString(value) == len(value) {
+			t.Errorf("Expected %s to contain non-ASCII characters, but was '%s'", name, value)
+		}
+	}
+	if email := r.PostForm.Get("email"); email != AutoFillEmail {
+		t.Errorf("Expected email to be '%s', but was '%s'", AutoFillEmail, email)
+	}
+}
+
+func TestAutoFill_pattern(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/signup">
+  <input type="text" name="phone" pattern="\+1 \d{3}-\d{4}" required>
+  <input type="text" name="postcode" pattern="[A-Z]{2}[0-9]{3,5}" required>
+  <input type="text" name="sku" pattern="(SKU|ITEM)-[a-f0-9]+" minlength="12" maxlength="14" required>
+  <input type="text" name="code" pattern="[^@\s]+" maxlength="3" required>
+  <input type="text" name="impossible" pattern="[a-z]{5}" maxlength="3" required>
+  <input type="text" name="empty" pattern="[^\x00-\x{10FFFF}]" required>
+</form>`))
+	form := doc.FirstForm()
+
+	_, err := form.PostParams(WithSeed(1), AutoFill())
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) != 2 ||
+		!validationErr.Has("impossible", ReasonRequired) || !validationErr.Has("empty", ReasonRequired) {
+		t.Fatalf("Expected only the impossible fields to be empty, but got %s", err)
+	}
+
+	doc = Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/signup">
+  <input type="text" name="phone" pattern="\+1 \d{3}-\d{4}" required>
+  <input type="text" name="sku" pattern="(SKU|ITEM)-[a-f0-9]+" minlength="12" maxlength="14" required>
+</form>`))
+	form = doc.FirstForm()
+	body, err := form.PostParams(WithSeed(1), AutoFill())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	again, err := form.PostParams(WithSeed(1), AutoFill())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if string(body) != string(again) {
+		t.Errorf("Expected the same seed to generate the same values, but got '%s' and '%s'", body, again)
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		t.Fatalf("Error parsing body: %s", err)
+	}
+	if phone := values.Get("phone"); !regexp.MustCompile(`^\+1 \d{3}-\d{4}$`).MatchString(phone) {
+		t.Errorf("Expected phone to match the pattern, but was '%s'", phone)
+	}
+	if sku := values.Get("sku"); len(sku) < 12 || len(sku) > 14 {
+		t.Errorf("Expected sku to be between 12 and 14 characters long, but was '%s'", sku)
+	}
+}
+
+func TestPattern_wholeValue(t *testing.T) {
+	form := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/signup">
+  <input type="text" name="ab" pattern="a|b" required>
+  <input type="password" name="pw" pattern="(?=.*\d).{8,}">
+  <input type="text" name="group" pattern="a)|(b">
+</form>`)).FirstForm()
+
+	for _, value := range []string{"a", "b"} {
+		if err := form.Validate(Set("ab", value)); err != nil {
+			t.Errorf("Expected '%s' to be valid, but got %s", value, err)
+		}
+	}
+	err := form.Validate(Set("ab", "ab"))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !validationErr.Has("ab", ReasonPattern) {
+		t.Errorf("Expected 'ab' not to match the pattern, but got %v", err)
+	}
+
+	// patterns which cannot be compiled are ignored
+	if err := form.Validate(Set("ab", "a"), Set("pw", "short"), Set("group", "x")); err != nil {
+		t.Errorf("Expected invalid patterns to be ignored, but got %s", err)
+	}
+
+	for i := int64(0); i < 20; i++ {
+		body, err := form.PostParams(WithSeed(i), AutoFill())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		values, _ := url.ParseQuery(string(body))
+		if ab := values.Get("ab"); ab != "a" && ab != "b" {
+			t.Errorf("Expected autofilled value to be 'a' or 'b', but was '%s'", ab)
+		}
+	}
+}
+
+type staticProvider map[string][]string
+
+func (p staticProvider) AutoFill(input Input) []string {
+	return p[input.Name()]
+}
+
+func TestWithAutoFill(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/signup">
+  <input type="text" name="first_name" required>
+  <input type="text" name="user[lastName]" required>
+  <input type="email" name="contact" required>
+  <input type="tel" name="phone" required>
+  <input type="text" name="zip" pattern="[0-9]{5}" required>
+  <input type="text" name="city" maxlength="2" required>
+  <input type="text" name="nickname" required>
+  <input type="number" name="age" min="18" required>
+</form>`))
+	form := doc.FirstForm()
+
+	body, err := form.PostParams(WithAutoFill(NewFaker(42)), AutoFill())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	again, err := form.PostParams(WithAutoFill(NewFaker(42)), AutoFill())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if string(body) != string(again) {
+		t.Errorf("Expected the same seed to generate the same values, but got '%s' and '%s'", body, again)
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		t.Fatalf("Error parsing body: %s", err)
+	}
+	for name, pattern := range map[string]string{
+		"first_name":     `^[A-Z][a-z]+$`,
+		"user[lastName]": `^[A-Z]\pL+$`,
+		"contact":        `^[a-z]+\.\d{3}@example\.com$`,
+		"phone":          `^\+1555\d{7}$`,
+		"zip":            `^\d{5}$`,
+		"nickname":       `^[a-z]+\d{3}$`,
+		"age":            `^18$`,
+	} {
+		if value := values.Get(name); !regexp.MustCompile(pattern).MatchString(value) {
+			t.Errorf("Expected %s to match %s, but was '%s'", name, pattern, value)
+		}
+	}
+	if city := values.Get("city"); len(city) != 2 {
+		t.Errorf("Expected city to fall back to a random value, but was '%s'", city)
+	}
+
+	body, err = form.PostParams(
+		WithAutoFill(staticProvider{"city": {"NY"}}),
+		AutoFill(),
+		Set("zip", "10001"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if values, _ := url.ParseQuery(string(body)); values.Get("city") != "NY" || values.Get("first_name") != "first_name-autofill" {
+		t.Errorf("Expected provider values with a fallback, but got '%s'", body)
+	}
+}
+
+func TestAutoFill_deterministic(t *testing.T) {
+	f := mustOpen(t, "./forms/big-empty.html")
+	defer f.Close()
+	form := Parse(f).FirstForm()
+
+	body, err := form.GetParams(AutoFill())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		again, err := form.GetParams(AutoFill())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if again != body {
+			t.Fatalf("Expected AutoFill to generate the same values, but got '%s' and '%s'", body, again)
+		}
+	}
+}
+
+func TestAutoFill_autocomplete(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/checkout">
+  <input type="text" name="a" autocomplete="section-blue shipping given-name" required>
+  <input type="text" name="b" autocomplete="postal-code" required>
+  <input type="text" name="card" autocomplete="cc-number" required>
+  <input type="text" name="exp" autocomplete="cc-exp" required>
+  <input type="password" name="pw1" autocomplete="new-password" required>
+  <input type="password" name="pw2" autocomplete="new-password" required>
+  <input type="text" name="code" autocomplete="one-time-code webauthn" pattern="[0-9]{6}" required>
+  <input type="text" name="other" autocomplete="on" required>
+</form>`))
+	form := doc.FirstForm()
+
+	if ac := form.Inputs["a"].Autocomplete(); ac != "given-name" {
+		t.Errorf("Expected autocomplete to be 'given-name', but was '%s'", ac)
+	}
+	if ac := form.Inputs["code"].Autocomplete(); ac != "one-time-code" {
+		t.Errorf("Expected autocomplete to be 'one-time-code', but was '%s'", ac)
+	}
+
+	body, err := form.PostParams(AutoFill())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		t.Fatalf("Error parsing body: %s", err)
+	}
+	for name, pattern := range map[string]string{
+		"a":     `^[A-Z][a-z]+$`,
+		"b":     `^\d{5}$`,
+		"card":  `^4\d{15}$`,
+		"exp":   `^\d{2}/\d{2}$`,
+		"code":  `^\d{6}$`,
+		"other": `^other-autofill$`,
+	} {
+		if value := values.Get(name); !regexp.MustCompile(pattern).MatchString(value) {
+			t.Errorf("Expected %s to match %s, but was '%s'", name, pattern, value)
+		}
+	}
+	if values.Get("pw1") == "" || values.Get("pw1") != values.Get("pw2") {
+		t.Errorf("Expected new passwords to match, but got '%s' and '%s'", values.Get("pw1"), values.Get("pw2"))
+	}
+
+	card := values.Get("card")
+	sum := 0
+	for i := range card {
+		digit := int(card[len(card)-1-i] - '0')
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	if sum%10 != 0 {
+		t.Errorf("Expected card number '%s' to pass the Luhn check", card)
+	}
+}
+
+func TestAutoFill_newPasswordConstraints(t *testing.T) {
+	form := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/signup">
+  <input type="password" name="password" maxlength="8" required>
+  <input type="password" name="password_confirm" maxlength="8" required>
+  <input type="password" name="pin" autocomplete="new-password" pattern="[0-9]{4}">
+  <input type="password" name="pin_confirm" autocomplete="new-password" pattern="[0-9]{4}">
+</form>`)).FirstForm()
+
+	body, err := form.PostParams(WithAutoFill(NewFaker(1)), AutoFill())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	values, _ := url.ParseQuery(string(body))
+	if pw := values.Get("password"); len(pw) == 0 || len(pw) > 8 || pw != values.Get("password_confirm") {
+		t.Errorf("Expected matching passwords of up to 8 characters, but got '%s' and '%s'", pw, values.Get("password_confirm"))
+	}
+
+	body, err = form.PostParams(Set("password", "secret"), Set("password_confirm", "secret"), AutoFillAll())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	values, _ = url.ParseQuery(string(body))
+	if pin := values.Get("pin"); !regexp.MustCompile(`^[0-9]{4}$`).MatchString(pin) || pin != values.Get("pin_confirm") {
+		t.Errorf("Expected matching pins, but got '%s' and '%s'", pin, values.Get("pin_confirm"))
+	}
+}
+
+func TestAutoFillAll(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form method="post" action="/profile">
+  <input type="text" name="nick" maxlength="4">
+  <input type="text" name="bio">
+  <input type="text" name="city" value="Zagreb">
+  <input type="number" name="age" min="18">
+  <input type="checkbox" name="news" value="yes">
+  <input type="radio" name="plan" value="free">
+  <input type="radio" name="plan" value="pro">
+  <input type="email" name="email" required>
+  <input type="text" name="locked" readonly>
+  <input type="text" name="off" disabled>
+  <input type="hidden" name="token">
+</form>`))
+	form := doc.FirstForm()
+
+	body, err := form.PostParams(AutoFillAll())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		t.Fatalf("Error parsing body: %s", err)
+	}
+	expected := url.Values{
+		"nick":   []string{values.Get("nick")},
+		"bio":    []string{"bio-autofill"},
+		"city":   []string{"Zagreb"},
+		"age":    []string{"18"},
+		"news":   []string{"yes"},
+		"plan":   []string{"free"},
+		"email":  []string{AutoFillEmail},
+		"locked": []string{""},
+		"token":  []string{""},
+	}
+	if !reflect.DeepEqual(expected, values) {
+		t.Errorf("Expected values to be:\n%v\nbut were:\n%v", expected, values)
+	}
+	if nick := values.Get("nick"); len(nick) != 4 {
+		t.Errorf("Expected nick to be 4 characters long, but was '%s'", nick)
+	}
+
+	body, err = form.PostParams(AutoFillExcept("bio", "news"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	values, _ = url.ParseQuery(string(body))
+	if values.Get("bio") != "" || len(values["news"]) != 0 || values.Get("age") != "18" {
+		t.Errorf("Expected bio and news to be skipped, but got '%s'", body)
+	}
+
+	err = form.Validate(AutoFillExcept("missing"))
+	expectedErr := "Cannot find input name='missing'"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected error '%s', but got %s", expectedErr, err)
+	}
+}
@@ -3,8 +3,13 @@ package gosubmit
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
 
 	"golang.org/x/net/html"
+	"golang.org/x/text/encoding"
 )
 
 type errorContainer struct {
@@ -25,11 +30,92 @@ func (e *errorContainer) Err() error {
 
 type Document struct {
 	errorContainer
+	root  *html.Node
 	forms Forms
 }
 
 type Inputs map[string]Input
 
+// Resolves form actions and formaction attributes of buttons against the
+// base URL of the document: the href of the first <base> element, resolved
+// against the document URL. Actions stay relative when neither is known.
+func (d *Document) resolveURLs(documentURL *url.URL) {
+	base := documentURL
+	if n := findElement(d.root, "base", "href"); n != nil {
+		href, err := url.Parse(strings.TrimSpace(getAttr(n, "href")))
+		if err == nil && base != nil {
+			base = base.ResolveReference(href)
+		} else if err == nil {
+			base = href
+		}
+	}
+	resolve := func(rawurl string) (*url.URL, error) {
+		u, err := url.Parse(strings.TrimSpace(rawurl))
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing url '%s': %w", rawurl, err)
+		}
+		if base != nil {
+			u = base.ResolveReference(u)
+		}
+		return u, nil
+	}
+	for i := range d.forms {
+		form := &d.forms[i]
+		form.documentURL = documentURL
+		action, err := resolve(form.URL)
+		if err != nil {
+			form.setError(err)
+			continue
+		}
+		form.Action = action
+		form.URL = action.String()
+		for j := range form.Buttons {
+			button := &form.Buttons[j]
+			if button.URL == "" {
+				continue
+			}
+			action, err := resolve(button.URL)
+			if err != nil {
+				form.setError(err)
+				continue
+			}
+			button.Action = action
+			button.URL = action.String()
+		}
+	}
+}
+
+// Sets the charset used to submit each form: the first supported charset
+// from its accept-charset attribute, or the charset of the document.
+func (d *Document) setCharset(e encoding.Encoding, name string) {
+	for i := range d.forms {
+		form := &d.forms[i]
+		form.Charset, form.encoding = name, e
+		if form.node == nil {
+			continue
+		}
+		if e, name, ok := getAcceptCharset(form.node); ok {
+			form.Charset, form.encoding = name, e
+		}
+	}
+}
+
+// Returns the first element with the tag name and attribute.
+func findElement(n *html.Node, tag string, attr string) *html.Node {
+	if n == nil {
+		return nil
+	}
+	if n.Type == html.ElementNode && n.Data == tag && hasAttr(n, attr) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag, attr); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
 func (d Document) Forms() Forms {
 	return d.forms
 }
@@ -71,6 +157,37 @@ func (d Document) FindForm(attrKey string, attrValue string) (form Form) {
 	return
 }
 
+// Returns all forms matching the CSS selector, for example
+// "main form#checkout.primary" or "form[action$='/save']".
+func (d Document) Select(selector string) (forms Forms, err error) {
+	sel, err := compileSelector(selector)
+	if err != nil {
+		return
+	}
+	for _, f := range d.forms {
+		if sel.match(f.node) {
+			forms = append(forms, f)
+		}
+	}
+	return
+}
+
+// Returns the first form matching the CSS selector.
+func (d Document) FormBySelector(selector string) (form Form) {
+	forms, err := d.Select(selector)
+	if err != nil {
+		form.Inputs = make(Inputs)
+		form.setError(err)
+		return
+	}
+	if len(forms) == 0 {
+		form.Inputs = make(Inputs)
+		form.setError(fmt.Errorf("No form matching selector '%s' found", selector))
+		return
+	}
+	return forms[0]
+}
+
 func (d Document) FindFormsByClass(className string) (forms Forms) {
 	for _, f := range d.forms {
 		for _, class := range f.ClassList {
@@ -84,20 +201,62 @@ func (d Document) FindFormsByClass(className string) (forms Forms) {
 
 type Form struct {
 	errorContainer
+	node     *html.Node
+	controls []*html.Node
 	// All html attributes of the form. Used to find the form by attribute
 	Attr      []html.Attribute
 	ClassList []string
 	// Value of Enctype attribute, default is application/x-www-form-urlencoded.
 	// For forms with file uploads it should be multipart/form-data.
 	ContentType string
-	// All found inputs
+	// All found inputs in document order. Checkboxes and radios sharing a
+	// name are grouped into a single input.
+	Fields []Input
+	// Index of inputs by name. When there are multiple inputs with the same
+	// name, the last one is used. Set and Add fill the first field in Fields
+	// with the name which is not disabled or readonly.
 	Inputs Inputs
 	// Value of form method attribute
 	Method string
-	// Value form action attribute
+	// Value of form action attribute, resolved against the URL of the document
+	// (see ParseWithURL and ParseResponse) and <base href>.
 	URL string
+	// Same as URL. Nil when the action could not be parsed.
+	Action *url.URL
 	// All found <button type="submit"> and <input type="submit"> elements.
 	Buttons []Button
+	// True when the form has the novalidate attribute. Required fields are
+	// not checked when it is set.
+	NoValidate bool
+	// Name of the charset used to encode submitted values, like "utf-8" or
+	// "iso-8859-2". Characters which are not supported by the charset are
+	// submitted as HTML character references, like browsers do.
+	Charset string
+	// nil for UTF-8
+	encoding encoding.Encoding
+	// Cookies set by the response which contained the form, sent with
+	// requests created from the form. See ParseResponse.
+	Cookies []*http.Cookie
+	// Headers sent with requests created from the form. See ParseResponse.
+	Header http.Header
+	// used to store cookies in a cookie jar
+	documentURL *url.URL
+}
+
+// Returns fields in document order. Falls back to inputs sorted by name for
+// forms which were created without fields.
+func (f Form) fields() []Input {
+	if f.Fields != nil || len(f.Inputs) == 0 {
+		return f.Fields
+	}
+	fields := make([]Input, 0, len(f.Inputs))
+	for _, input := range f.Inputs {
+		fields = append(fields, input)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name() < fields[j].Name()
+	})
+	return fields
 }
 
 // Returns true if field is required, false otherwise.
@@ -116,10 +275,14 @@ func (f Form) newFiller(opts []Option) (filler *filler, err error) {
 }
 
 // Fills the form and returns an error if there was an error. Useful for
-// testing.
+// testing. Invalid and missing required values are reported together in a
+// ValidationError.
 func (f Form) Validate(opts ...Option) error {
-	_, err := f.newFiller(opts)
-	return err
+	filler, err := f.newFiller(opts)
+	if err != nil {
+		return err
+	}
+	return filler.validateForm()
 }
 
 // Fills the form and returns a new request. If there was any error in the
@@ -171,6 +334,88 @@ func (f Form) PostParams(opts ...Option) ([]byte, error) {
 	return filler.BuildPost()
 }
 
+// Fills the form and serves a new test request using handler. Returns the
+// recorded response. Redirects are not followed (see Session).
+func (f Form) SubmitTo(handler http.Handler, opts ...Option) (*httptest.ResponseRecorder, error) {
+	_, w, err := f.serve(handler, opts)
+	return w, err
+}
+
+// Same as SubmitTo, but parses and returns the received page.
+func (f Form) SubmitToDocument(handler http.Handler, opts ...Option) (Document, error) {
+	r, w, err := f.serve(handler, opts)
+	if err != nil {
+		return Document{}, err
+	}
+	res := w.Result()
+	res.Request = r
+	doc := ParseResponse(res, nil)
+	return doc, doc.Err()
+}
+
+func (f Form) serve(handler http.Handler, opts []Option) (*http.Request, *httptest.ResponseRecorder, error) {
+	r, err := f.NewTestRequest(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	// like net/http servers, so multipart bodies which were not read to
+	// the end stop being written
+	r.Body.Close()
+	return r, w, nil
+}
+
+// Fills the form and sends a new request using client. The form URL has to
+// be absolute, see ParseResponse. The caller has to close the response body.
+func (f Form) Do(client *http.Client, opts ...Option) (*http.Response, error) {
+	r, err := f.NewRequest(opts...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(r)
+	if err != nil {
+		return nil, fmt.Errorf("Error sending request: %w", err)
+	}
+	return res, nil
+}
+
+// Same as Do, but parses and returns the received page. Form actions on the
+// page are resolved against the URL of the last request, after redirects.
+func (f Form) DoDocument(client *http.Client, opts ...Option) (Document, error) {
+	res, err := f.Do(client, opts...)
+	if err != nil {
+		return Document{}, err
+	}
+	defer res.Body.Close()
+	doc := ParseResponse(res, nil)
+	return doc, doc.Err()
+}
+
+// Returns the inputs of the form whose elements match the CSS selector, in
+// document order. Elements associated using the form attribute are included.
+// Inputs sharing a name (like checkboxes) are returned once.
+func (f Form) Select(selector string) (inputs []Input, err error) {
+	sel, err := compileSelector(selector)
+	if err != nil {
+		return
+	}
+	found := map[string]struct{}{}
+	for _, n := range f.controls {
+		if !sel.match(n) {
+			continue
+		}
+		name := getAttr(n, "name")
+		input, ok := f.Inputs[name]
+		if _, dup := found[name]; !ok || dup {
+			continue
+		}
+		found[name] = struct{}{}
+		inputs = append(inputs, input)
+	}
+	return
+}
+
 // Returns a list of available input values for elements with options
 // (checkbox, radio or select).
 func (f Form) GetOptionsFor(name string) (options []string) {
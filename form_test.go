@@ -3,8 +3,17 @@ package gosubmit_test
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/jeremija/gosubmit"
 )
@@ -112,3 +121,405 @@ func TestFirstForm(t *testing.T) {
 		t.Errorf("Expected an error 'No forms found', but got %s", err)
 	}
 }
+
+func TestDocument_Select(t *testing.T) {
+	r := bytes.NewReader([]byte(`<!DOCTYPE html>
+<html>
+<body>
+<main>
+<section class="cart">
+<form id="checkout" class="primary" action="/checkout/save">
+<div><input type="text" name="coupon" data-role="coupon-code"></div>
+<input type="checkbox" name="gift" value="wrap">
+<input type="checkbox" name="gift" value="card">
+</form>
+</section>
+<form id="checkout-secondary" class="secondary" action="/checkout/draft"></form>
+</main>
+<form id="search" action="/search"></form>
+</body>
+</html>
+`))
+	doc := Parse(r)
+	if err := doc.Err(); err != nil {
+		t.Fatalf("Unexpected Parse error: %s", err)
+	}
+
+	for selector, expected := range map[string][]string{
+		"form#checkout.primary":       {"checkout"},
+		"main form":                   {"checkout", "checkout-secondary"},
+		"main > form":                 {"checkout-secondary"},
+		"section + form":              {"checkout-secondary"},
+		"section ~ form, body > form": {"checkout-secondary", "search"},
+		"form[action^='/checkout']":   {"checkout", "checkout-secondary"},
+		`form[action$="draft"]`:       {"checkout-secondary"},
+		"form[id|=checkout]":          {"checkout", "checkout-secondary"},
+		"form[class~=primary]":        {"checkout"},
+		"form:not(.primary, #search)": {"checkout-secondary"},
+		"body > :last-child":          {"search"},
+		"div form":                    nil,
+	} {
+		forms, err := doc.Select(selector)
+		if err != nil {
+			t.Errorf("Unexpected error for selector '%s': %s", selector, err)
+			continue
+		}
+		var ids []string
+		for _, form := range forms {
+			for _, attr := range form.Attr {
+				if attr.Key == "id" {
+					ids = append(ids, attr.Val)
+				}
+			}
+		}
+		if !reflect.DeepEqual(expected, ids) {
+			t.Errorf("Expected selector '%s' to find %v, but got %v", selector, expected, ids)
+		}
+	}
+
+	form := doc.FormBySelector("section form")
+	if err := form.Err(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	inputs, err := form.Select("div [data-role=coupon-code], input[type=checkbox]")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(inputs) != 2 || inputs[0].Name() != "coupon" || inputs[1].Name() != "gift" {
+		t.Errorf("Expected to find inputs coupon and gift, but got %v", inputs)
+	}
+}
+
+func TestDocument_Select_error(t *testing.T) {
+	doc := Parse(bytes.NewReader([]byte(`<form id="a"></form>`)))
+
+	for selector, expected := range map[string]string{
+		"form[id=":   "Invalid selector 'form[id=': unexpected end of selector",
+		"form:hover": "Invalid selector 'form:hover': unsupported pseudo-class ':hover'",
+		"form >":     "Invalid selector 'form >': expected selector at position 6",
+		"#b":         "No form matching selector '#b' found",
+	} {
+		form := doc.FormBySelector(selector)
+		if err := form.Err(); err == nil || err.Error() != expected {
+			t.Errorf("Expected error '%s', but got %s", expected, err)
+		}
+	}
+}
+
+func TestParseWithURL_resolveActions(t *testing.T) {
+	page := `<!DOCTYPE html>
+<form id="up" action="../save"></form>
+<form id="query" action="?step=2"><input name="a" value="b"></form>
+<form id="empty"></form>
+<form id="absolute" action="https://other.example.com/x"></form>
+<form id="button" action="save">
+  <button formaction="/draft">Draft</button>
+</form>`
+
+	doc := ParseWithURL(bytes.NewReader([]byte(page)), "http://example.com/shop/cart/view?id=1")
+	for id, expected := range map[string]string{
+		"up":       "http://example.com/shop/save",
+		"query":    "http://example.com/shop/cart/view?step=2",
+		"empty":    "http://example.com/shop/cart/view?id=1",
+		"absolute": "https://other.example.com/x",
+		"button":   "http://example.com/shop/cart/save",
+	} {
+		form := doc.FindForm("id", id)
+		if form.URL != expected || form.Action == nil || form.Action.String() != expected {
+			t.Errorf("Expected form %s action to be '%s', but was '%s' (%v)", id, expected, form.URL, form.Action)
+		}
+	}
+	button := doc.FindForm("id", "button").Buttons[0]
+	if expected := "http://example.com/draft"; button.URL != expected || button.Action.String() != expected {
+		t.Errorf("Expected formaction to be '%s', but was '%s'", expected, button.URL)
+	}
+
+	r, err := doc.FindForm("id", "query").NewRequest()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if expected := "http://example.com/shop/cart/view?a=b"; r.URL.String() != expected {
+		t.Errorf("Expected GET url to be '%s', but was '%s'", expected, r.URL)
+	}
+
+	doc = ParseWithURL(bytes.NewReader([]byte(`<!DOCTYPE html>
+<head><base href="/app/"></head>
+`+page)), "http://example.com/shop/cart/view")
+	if url := doc.FindForm("id", "up").URL; url != "http://example.com/save" {
+		t.Errorf("Expected action to be resolved against base href, but was '%s'", url)
+	}
+	if url := doc.FindForm("id", "empty").URL; url != "http://example.com/app/" {
+		t.Errorf("Expected empty action to be the base URL, but was '%s'", url)
+	}
+
+	res := &http.Response{
+		Body:    ioutil.NopCloser(bytes.NewReader([]byte(page))),
+		Request: httptest.NewRequest("GET", "http://example.com/shop/cart/view", nil),
+	}
+	if url := ParseResponse(res, nil).FindForm("id", "up").URL; url != "http://example.com/shop/save" {
+		t.Errorf("Expected action to be resolved against the request URL, but was '%s'", url)
+	}
+}
+
+func TestParseResponse_charset(t *testing.T) {
+	// "Čakovec" and "šuma" in ISO-8859-2
+	page := "<!DOCTYPE html>\n" +
+		`<form method="post" action="/save">` +
+		`<input type="hidden" name="city" value="` + "\xc8akovec" + `">` +
+		`<select name="word"><option>` + "\xb9uma" + `</option></select>` +
+		`</form>`
+	res := &http.Response{
+		Header:  http.Header{"Content-Type": []string{"text/html; charset=ISO-8859-2"}},
+		Body:    ioutil.NopCloser(strings.NewReader(page)),
+		Request: httptest.NewRequest("GET", "/", nil),
+	}
+	form := ParseResponse(res, nil).FirstForm()
+	if form.Charset != "iso-8859-2" {
+		t.Errorf("Expected charset to be iso-8859-2, but was %s", form.Charset)
+	}
+	if city := form.Inputs["city"].Value(); city != "Čakovec" {
+		t.Errorf("Expected city to be decoded to 'Čakovec', but was '%s'", city)
+	}
+	if word := form.Inputs["word"].Value(); word != "šuma" {
+		t.Errorf("Expected word to be decoded to 'šuma', but was '%s'", word)
+	}
+
+	body, err := form.PostParams(Set("word", "šuma"), ForceSet("city", "Đakovo €"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// € is not supported by ISO-8859-2 so it is sent as a character reference
+	if expected := "city=%D0akovo+%26%238364%3B&word=%B9uma"; string(body) != expected {
+		t.Errorf("Expected body to be '%s', but was '%s'", expected, body)
+	}
+}
+
+func TestParse_metaCharset(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<meta charset="windows-1250">
+<form id="a"><input name="q" value="` + "\x9ae\xe6er" + `"></form>
+<form id="b" accept-charset="bogus UTF-8"><input name="q"></form>`))
+
+	a := doc.FindForm("id", "a")
+	if a.Charset != "windows-1250" || a.Inputs["q"].Value() != "šećer" {
+		t.Errorf("Expected windows-1250 value 'šećer', but got %s '%s'", a.Charset, a.Inputs["q"].Value())
+	}
+	query, err := a.GetParams()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if expected := "q=%9Ae%E6er"; query != expected {
+		t.Errorf("Expected query to be '%s', but was '%s'", expected, query)
+	}
+
+	b := doc.FindForm("id", "b")
+	query, err = b.GetParams(Set("q", "šećer"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if expected := "q=%C5%A1e%C4%87er"; b.Charset != "utf-8" || query != expected {
+		t.Errorf("Expected utf-8 query '%s', but got %s '%s'", expected, b.Charset, query)
+	}
+
+	doc = Parse(strings.NewReader(`<!DOCTYPE html><form><input name="q" value="šećer"></form>`))
+	if form := doc.FirstForm(); form.Charset != "utf-8" || form.Inputs["q"].Value() != "šećer" {
+		t.Errorf("Expected documents without a charset to be utf-8, but got %s", form.Charset)
+	}
+
+	doc = Parse(strings.NewReader(`<!DOCTYPE html><p>Pick a charset below</p>` + strings.Repeat(" ", 1024) +
+		`<form><input type="hidden" name="city" value="Čakovec"></form>`))
+	if form := doc.FirstForm(); form.Charset != "utf-8" || form.Inputs["city"].Value() != "Čakovec" {
+		t.Errorf("Expected the word charset not to declare a charset, but got %s '%s'", form.Charset, form.Inputs["city"].Value())
+	}
+
+	doc = Parse(strings.NewReader(`<!DOCTYPE html>
+<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-2">
+<form><input name="q" value="` + "\xb9uma" + `"></form>`))
+	if form := doc.FirstForm(); form.Charset != "iso-8859-2" || form.Inputs["q"].Value() != "šuma" {
+		t.Errorf("Expected iso-8859-2 value 'šuma', but got %s '%s'", form.Charset, form.Inputs["q"].Value())
+	}
+}
+
+func TestForm_SubmitTo(t *testing.T) {
+	app := newWizard()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/login", nil)
+	app.ServeHTTP(w, r)
+	form := ParseResponse(w.Result(), r.URL).FirstForm()
+
+	w, err := form.SubmitTo(app, Set("username", "user"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/wizard/step1" {
+		t.Errorf("Expected a redirect to /wizard/step1, but got %d %s", w.Code, w.Header().Get("Location"))
+	}
+
+	if _, err := form.SubmitTo(app, Set("missing", "x")); err == nil {
+		t.Error("Expected an error for a missing input")
+	}
+
+	step1 := ParseWithURL(strings.NewReader(`<!DOCTYPE html>
+<form method="POST" action="/login"><input name="username"><input type="hidden" name="csrf" value="1234"></form>`), "/login").FirstForm()
+	doc, err := step1.SubmitToDocument(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<form action="next"><input name="user" value="` + r.FormValue("username") + `"></form>`))
+	}), Set("username", "joe"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	next := doc.FirstForm()
+	if next.URL != "/next" || next.Inputs["user"].Value() != "joe" {
+		t.Errorf("Expected the next page to be parsed, but got %s %v", next.URL, next.Inputs)
+	}
+}
+
+func TestForm_Do(t *testing.T) {
+	server := httptest.NewServer(newWizard())
+	defer server.Close()
+	client := server.Client()
+	client.Jar, _ = cookiejar.New(nil)
+
+	res, err := client.Get(server.URL + "/login")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	form := ParseResponse(res, nil).FirstForm()
+	res.Body.Close()
+
+	doc, err := form.DoDocument(client, Set("username", "user"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	step1 := doc.FirstForm()
+	if expected := server.URL + "/wizard/step1"; step1.URL != expected {
+		t.Fatalf("Expected form action to be '%s', but was '%s'", expected, step1.URL)
+	}
+
+	res, err = step1.Do(client, Set("color", "blue"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	if string(body) != "Done: blue" {
+		t.Errorf("Expected body to be 'Done: blue', but was '%s'", body)
+	}
+}
+
+func TestForm_SubmitTo_unreadBody(t *testing.T) {
+	form := Parse(strings.NewReader(`<form method="post" action="/upload" enctype="multipart/form-data">
+<input type="file" name="upload">
+</form>`)).FirstForm()
+	tooLarge := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	})
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		w, err := form.SubmitTo(tooLarge,
+			AddFileReader("upload", "large.bin", io.LimitReader(zeroReader{}, 1<<20), 1<<20))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413, but got %d", w.Code)
+		}
+	}
+	waitForGoroutines(t, before)
+}
+
+// Fails when the number of goroutines does not drop back to count.
+func waitForGoroutines(t *testing.T, count int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if runtime.NumGoroutine() <= count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected %d goroutines, but got %d", count, runtime.NumGoroutine())
+}
+
+func newCSRFHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			c, err := r.Cookie("csrf")
+			if err != nil || c.Value != r.FormValue("csrf") || r.Header.Get("Authorization") != "Bearer t1" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte("OK"))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "csrf", Value: "abcd", Path: "/"})
+		http.SetCookie(w, &http.Cookie{Name: "old", Value: "x", MaxAge: -1})
+		w.Write([]byte(`<form method="POST" action="/comment"><input type="hidden" name="csrf" value="abcd"></form>`))
+	}
+}
+
+func TestParseResponse_cookiesAndHeaders(t *testing.T) {
+	handler := newCSRFHandler()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/comment", nil)
+	r.Header.Set("Authorization", "Bearer t1")
+	handler.ServeHTTP(w, r)
+	res := w.Result()
+	// set by http.Client, but not by httptest.ResponseRecorder
+	res.Request = r
+	form := ParseResponse(res, nil, "Authorization").FirstForm()
+
+	if len(form.Cookies) != 2 || form.Header.Get("Authorization") != "Bearer t1" {
+		t.Fatalf("Expected cookies and headers to be captured, but got %v %v", form.Cookies, form.Header)
+	}
+
+	req, err := form.NewTestRequest()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if cookie := req.Header.Get("Cookie"); cookie != "csrf=abcd" {
+		t.Errorf("Expected only unexpired cookies to be sent, but got '%s'", cookie)
+	}
+
+	w, err = form.SubmitTo(handler)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, but got %d", w.Code)
+	}
+
+	// without the header
+	form = ParseResponse(w.Result(), r.URL).FirstForm()
+	if len(form.Header) != 0 {
+		t.Errorf("Expected no headers, but got %v", form.Header)
+	}
+}
+
+func TestWithCookieJar(t *testing.T) {
+	handler := newCSRFHandler()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/comment", nil)
+	handler.ServeHTTP(w, r)
+	form := ParseResponse(w.Result(), r.URL).FirstForm()
+
+	jar, _ := cookiejar.New(nil)
+	u, _ := url.Parse("http://example.com/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "s1"}})
+
+	req, err := form.NewTestRequest(WithCookieJar(jar))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if c, err := req.Cookie("session"); err != nil || c.Value != "s1" {
+		t.Errorf("Expected the session cookie from the jar to be sent, but got %v", req.Cookies())
+	}
+	if c, err := req.Cookie("csrf"); err != nil || c.Value != "abcd" {
+		t.Errorf("Expected the csrf cookie to be sent, but got %v", req.Cookies())
+	}
+	if cookies := jar.Cookies(u); len(cookies) != 2 {
+		t.Errorf("Expected the csrf cookie to be stored in the jar, but got %v", cookies)
+	}
+}
@@ -2,4 +2,7 @@ module github.com/jeremija/gosubmit
 
 go 1.13
 
-require golang.org/x/net v0.0.0-20200202094626-16171245cfb2
+require (
+	golang.org/x/net v0.0.0-20200202094626-16171245cfb2
+	golang.org/x/text v0.3.0
+)
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"golang.org/x/net/html"
@@ -26,27 +27,45 @@ const (
 	InputTypeRadio    = "radio"
 	InputTypeHidden   = "hidden"
 	InputTypeSubmit   = "submit"
+	InputTypeImage    = "image"
 	InputTypeEmail    = "email"
 	InputTypeURL      = "url"
 	InputTypeDate     = "date"
 	InputTypeNumber   = "number"
+
+	InputTypeTime          = "time"
+	InputTypeDateTimeLocal = "datetime-local"
+	InputTypeMonth         = "month"
+	InputTypeWeek          = "week"
+	InputTypeColor         = "color"
+	InputTypeRange         = "range"
+	InputTypeTel           = "tel"
+	InputTypeSearch        = "search"
+	InputTypePassword      = "password"
 )
 
-// Parse all formsr in the HTML document and set the default URL if <form
-// action="..."> attribute is missing
+// Parse all forms in the HTML document. Form actions are resolved against
+// defaultURL (and any <base href> element), so the action of a form without
+// an action attribute is defaultURL.
 func ParseWithURL(r io.Reader, defaultURL string) (doc Document) {
-	doc = Parse(r)
-	for index, form := range doc.forms {
-		if form.URL == "" {
-			form.URL = defaultURL
-			doc.forms[index] = form
-		}
+	u, err := url.Parse(defaultURL)
+	if err != nil {
+		doc.setError(fmt.Errorf("Error parsing url '%s': %w", defaultURL, err))
+		return
 	}
-	return
+	return parse(r, u, "")
 }
 
-// Parse all forms in the HTML document.
+// Parse all forms in the HTML document. Form actions are only resolved
+// against a <base href> element, if there is one.
 func Parse(r io.Reader) (doc Document) {
+	return parse(r, nil, "")
+}
+
+// Parses the document, decoding it to UTF-8 using the charset from
+// contentType or <meta charset>.
+func parse(r io.Reader, documentURL *url.URL, contentType string) (doc Document) {
+	r, e, name := decodeDocument(r, contentType)
 	n, err := html.Parse(r)
 	if err != nil {
 		doc.setError(fmt.Errorf("Error parsing html: %w", err))
@@ -54,30 +73,98 @@ func Parse(r io.Reader) (doc Document) {
 	}
 
 	doc = findForms(n)
+	doc.root = n
+	doc.resolveURLs(documentURL)
+	doc.setCharset(e, name)
 	return
 }
 
-func ParseResponse(r *http.Response, url *url.URL) Document {
-	return ParseWithURL(r.Body, url.EscapedPath())
+// Parses forms in the response body. Form actions are resolved against u, or
+// against the URL of the request which was sent when u is nil. The body is
+// decoded using the charset of the Content-Type header, or <meta charset>.
+//
+// Cookies set by the response are sent with requests created from the forms,
+// like CSRF cookies. The request headers with names in headers (like
+// Authorization) are copied from the request which was sent, if any.
+func ParseResponse(r *http.Response, u *url.URL, headers ...string) Document {
+	if u == nil && r.Request != nil {
+		u = r.Request.URL
+	}
+	doc := parse(r.Body, u, r.Header.Get("Content-Type"))
+	cookies := r.Cookies()
+	header := make(http.Header)
+	if r.Request != nil {
+		for _, key := range headers {
+			for _, value := range r.Request.Header[http.CanonicalHeaderKey(key)] {
+				header.Add(key, value)
+			}
+		}
+	}
+	for i := range doc.forms {
+		doc.forms[i].Cookies = cookies
+		if len(header) > 0 {
+			doc.forms[i].Header = header
+		}
+	}
+	return doc
 }
 
 var PatternEmail = regexp.MustCompile("[a-z0-9._%+-]+@[a-z0-9.-]+\\.[a-z]{2,}$")
 var PatternURL = regexp.MustCompile("^https?://.+")
 
 func findForms(n *html.Node) (doc Document) {
-	var recursivelyFindDocument func(n *html.Node)
-	recursivelyFindDocument = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "form" {
-			form := createForm(n)
-			form.setError(doc.err)
-			doc.forms = append(doc.forms, form)
-			return
+	var formNodes []*html.Node
+	ids := map[string]*html.Node{}
+	controls := map[*html.Node][]*html.Node{}
+
+	var recursivelyFindForms func(n *html.Node)
+	recursivelyFindForms = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if id, ok := getAttrOK(n, "id"); ok {
+				if _, exists := ids[id]; !exists {
+					ids[id] = n
+				}
+			}
+			if n.Data == "form" {
+				formNodes = append(formNodes, n)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			recursivelyFindForms(c)
+		}
+	}
+	recursivelyFindForms(n)
+
+	// Controls belong to the form referenced by their form attribute, or to
+	// the closest ancestor form when the attribute is missing.
+	var recursivelyFindControls func(n *html.Node, ancestor *html.Node)
+	recursivelyFindControls = func(n *html.Node, ancestor *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "form":
+				ancestor = n
+			case ElementInput, ElementSelect, ElementTextArea, ElementButton:
+				owner := ancestor
+				if formID, ok := getAttrOK(n, "form"); ok {
+					owner = ids[formID]
+				}
+				if owner != nil && owner.Data == "form" {
+					controls[owner] = append(controls[owner], n)
+				}
+				return
+			}
 		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			recursivelyFindDocument(c)
+			recursivelyFindControls(c, ancestor)
 		}
 	}
-	recursivelyFindDocument(n)
+	recursivelyFindControls(n, nil)
+
+	for _, formNode := range formNodes {
+		form := createForm(formNode, controls[formNode])
+		form.setError(doc.err)
+		doc.forms = append(doc.forms, form)
+	}
 	return doc
 }
 
@@ -103,55 +190,82 @@ func getRadio(inputs Inputs, name string) (radio Radio, ok bool) {
 	return
 }
 
+// Returns the pattern attribute compiled so that it has to match the whole
+// value, like browsers do. Patterns which cannot be compiled (like ones with
+// lookaheads) are ignored, like browsers ignore invalid patterns.
 func getPattern(n *html.Node, defaultPattern *regexp.Regexp) *regexp.Regexp {
 	p := getAttr(n, "pattern")
 	if p == "" {
 		return defaultPattern
 	}
-	if !strings.HasPrefix(p, "^") {
-		p = "^" + p
-	}
-	if !strings.HasSuffix(p, "$") {
-		p = p + "$"
+	// compiled on its own first, so a pattern like a)|(b cannot escape
+	// the group
+	if _, err := regexp.Compile(p); err != nil {
+		return defaultPattern
 	}
-	return regexp.MustCompile(p)
+	return regexp.MustCompile("^(?:" + p + ")$")
 }
 
-func createForm(n *html.Node) (form Form) {
+func createForm(n *html.Node, controls []*html.Node) (form Form) {
 	inputs := Inputs{}
-	var recursivelyFindInputs func(n *html.Node)
-	recursivelyFindInputs = func(n *html.Node) {
-		if n.Type != html.ElementNode {
+	var fields []Input
+	// position of the last field with a name
+	positions := map[string]int{}
+	// Adds a new field, or updates the existing one when a group of
+	// checkboxes or radios was extended. Inputs without a name are never
+	// submitted so they are skipped.
+	addField := func(input Input, update bool) {
+		name := input.Name()
+		if name == "" {
 			return
 		}
-		inputType := getAttr(n, "type")
+		if update {
+			fields[positions[name]] = input
+		} else {
+			positions[name] = len(fields)
+			fields = append(fields, input)
+		}
+		inputs[name] = input
+	}
+	for _, n := range controls {
+		inputType := strings.ToLower(getAttr(n, "type"))
 		name := getAttr(n, "name")
 		required := hasAttr(n, "required")
+		disabled := isDisabled(n)
+		readOnly := hasAttr(n, "readonly") && supportsReadOnly(n.Data, inputType)
+		autocomplete := getAutocomplete(n)
 		switch n.Data {
 		case "select":
-			values, options, _ := findSelectOptions(n)
-			inputs[name] = Select{
+			values, options, labels := findSelectOptions(n)
+			addField(Select{
+				labels: labels,
 				inputWithOptions: inputWithOptions{
 					anyInput: anyInput{
-						name:      name,
-						inputType: inputType,
-						values:    values,
-						required:  required,
+						name:         name,
+						inputType:    inputType,
+						values:       values,
+						required:     required,
+						disabled:     disabled,
+						autocomplete: autocomplete,
 					},
 					multiple: hasAttr(n, "multiple"),
 					options:  options,
 				},
-			}
+			}, false)
 		case "input":
 			value := getAttr(n, "value")
 			anyInput := anyInput{
-				name:      name,
-				inputType: inputType,
-				values:    []string{value},
-				required:  required,
+				name:         name,
+				inputType:    inputType,
+				values:       []string{value},
+				required:     required,
+				disabled:     disabled,
+				readOnly:     readOnly,
+				autocomplete: autocomplete,
 			}
 			switch inputType {
 			case InputTypeCheckbox:
+				value = getCheckedValue(n)
 				i, ok := getCheckbox(inputs, name)
 				if !ok {
 					i = Checkbox{
@@ -163,17 +277,14 @@ func createForm(n *html.Node) (form Form) {
 					}
 					i.values = []string{}
 				}
-				if hasAttr(n, "checked") {
-					i.values = append(i.values, value)
-				}
-				i.options = append(i.options, value)
-				i.required = i.required || hasAttr(n, "required")
-				inputs[name] = i
+				i.inputWithOptions = addOption(i.inputWithOptions, n, value, disabled)
+				addField(i, ok)
 			case InputTypeFile:
-				inputs[name] = FileInput{
+				addField(FileInput{
 					anyInput: anyInput,
-				}
+				}, false)
 			case InputTypeRadio:
+				value = getCheckedValue(n)
 				i, ok := getRadio(inputs, name)
 				if !ok {
 					i = Radio{
@@ -184,72 +295,103 @@ func createForm(n *html.Node) (form Form) {
 					}
 					i.values = []string{}
 				}
-				i.options = append(i.options, value)
-				i.required = i.required || hasAttr(n, "required")
-				if hasAttr(n, "checked") {
-					i.values = append(i.values, value)
-				}
-				// need to reassing because map has plain struct (no pointers)
-				inputs[name] = i
+				i.inputWithOptions = addOption(i.inputWithOptions, n, value, disabled)
+				addField(i, ok)
 			case InputTypeHidden:
-				inputs[name] = HiddenInput{
+				addField(HiddenInput{
 					anyInput: anyInput,
-				}
-			case InputTypeSubmit:
-				form.Buttons = append(form.Buttons, Button{
-					Name:  name,
-					Value: getAttr(n, "value"),
-				})
+				}, false)
+			case InputTypeSubmit, InputTypeImage:
+				form.Buttons = append(form.Buttons, createButton(n))
 			case InputTypeEmail:
 				textInput := createTextInput(anyInput, n)
 				textInput.pattern = PatternEmail
-				inputs[name] = EmailInput{
+				addField(EmailInput{
 					TextInput: textInput,
-				}
+				}, false)
 			case InputTypeURL:
 				textInput := createTextInput(anyInput, n)
 				textInput.pattern = PatternURL
-				inputs[name] = URLInput{
+				addField(URLInput{
 					TextInput: textInput,
-				}
+				}, false)
 			case InputTypeDate:
-				inputs[name] = DateInput{
-					anyInput: anyInput,
+				addField(DateInput{
+					temporalInput: createTemporalInput(anyInput, n, dateFormat),
+				}, false)
+			case InputTypeTime:
+				addField(TimeInput{
+					temporalInput: createTemporalInput(anyInput, n, timeFormat),
+				}, false)
+			case InputTypeDateTimeLocal:
+				addField(DateTimeLocalInput{
+					temporalInput: createTemporalInput(anyInput, n, dateTimeLocalFormat),
+				}, false)
+			case InputTypeMonth:
+				addField(MonthInput{
+					temporalInput: createTemporalInput(anyInput, n, monthFormat),
+				}, false)
+			case InputTypeWeek:
+				addField(WeekInput{
+					temporalInput: createTemporalInput(anyInput, n, weekFormat),
+				}, false)
+			case InputTypeColor:
+				i := ColorInput{anyInput: anyInput}
+				if color, ok := i.Fill(value); ok {
+					i.values = []string{color}
+				} else {
+					i.values = []string{defaultColor}
 				}
+				addField(i, false)
+			case InputTypeRange:
+				addField(createRangeInput(anyInput, n), false)
+			case InputTypeTel:
+				addField(TelInput{
+					TextInput: createTextInput(anyInput, n),
+				}, false)
+			case InputTypeSearch:
+				addField(SearchInput{
+					TextInput: createTextInput(anyInput, n),
+				}, false)
+			case InputTypePassword:
+				addField(PasswordInput{
+					TextInput: createTextInput(anyInput, n),
+				}, false)
 			case InputTypeNumber:
-				inputs[name] = NumberInput{
-					anyInput: anyInput,
-					min:      atoi(getAttr(n, "min")),
-					max:      atoi(getAttr(n, "max")),
-				}
+				addField(createNumberInput(anyInput, n), false)
 			default:
-				inputs[name] = createTextInput(anyInput, n)
+				addField(createTextInput(anyInput, n), false)
 			}
 		case ElementTextArea:
-			inputs[name] = TextInput{
-				anyInput: anyInput{
-					name:      name,
-					inputType: "textarea",
-					values:    []string{getText(n)},
-				},
-				minLength: atoi(getAttr(n, "minlength")),
-				maxLength: atoi(getAttr(n, "maxlength")),
+			textInput := createTextInput(anyInput{
+				name:         name,
+				inputType:    ElementTextArea,
+				values:       []string{getText(n)},
+				required:     required,
+				disabled:     disabled,
+				readOnly:     readOnly,
+				autocomplete: autocomplete,
+			}, n)
+			// the pattern attribute does not apply to textareas
+			textInput.pattern = nil
+			wrap := strings.ToLower(getAttr(n, "wrap"))
+			if wrap != "hard" && wrap != "off" {
+				wrap = "soft"
 			}
+			addField(TextArea{
+				TextInput: textInput,
+				wrap:      wrap,
+			}, false)
 		case ElementButton:
-			if inputType == "submit" {
-				form.Buttons = append(form.Buttons, Button{
-					Name:  name,
-					Value: getAttr(n, "value"),
-				})
-			}
-		default:
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				recursivelyFindInputs(c)
+			// buttons with missing or invalid type attribute are submit
+			// buttons too
+			if inputType != "reset" && inputType != "button" {
+				form.Buttons = append(form.Buttons, createButton(n))
 			}
 		}
 	}
-	recursivelyFindInputs(n)
 	form.Inputs = inputs
+	form.Fields = fields
 	form.ContentType = getAttr(n, "enctype")
 	if form.ContentType == "" {
 		form.ContentType = ContentTypeForm
@@ -260,10 +402,124 @@ func createForm(n *html.Node) (form Form) {
 	}
 	form.ClassList = strings.Split(getAttr(n, "class"), " ")
 	form.URL = getAttr(n, "action")
+	form.NoValidate = hasAttr(n, "novalidate")
 	form.Attr = n.Attr
+	form.node = n
+	form.controls = controls
 	return
 }
 
+// Checkboxes and radios without a value attribute are submitted as "on".
+const defaultCheckedValue = "on"
+
+func getCheckedValue(n *html.Node) string {
+	value, ok := getAttrOK(n, "value")
+	if !ok {
+		return defaultCheckedValue
+	}
+	return value
+}
+
+// Adds a checkbox or radio to its group. Disabled elements are left out of
+// the group, unless all of its elements are disabled. In that case the whole
+// group is disabled.
+func addOption(i inputWithOptions, n *html.Node, value string, disabled bool) inputWithOptions {
+	if disabled && !i.disabled {
+		return i
+	}
+	if i.disabled && !disabled {
+		i.disabled = false
+		i.options = []string{}
+		i.values = []string{}
+	}
+	i.options = append(i.options, value)
+	if hasAttr(n, "checked") {
+		i.values = append(i.values, value)
+	}
+	i.required = i.required || hasAttr(n, "required")
+	return i
+}
+
+// Returns true if the element is disabled using its own disabled attribute
+// or by a disabled fieldset. Elements in the first legend of a disabled
+// fieldset are not disabled by it.
+func isDisabled(n *html.Node) bool {
+	if hasAttr(n, "disabled") {
+		return true
+	}
+	child := n
+	for p := n.Parent; p != nil; child, p = p, p.Parent {
+		if p.Type == html.ElementNode && p.Data == "fieldset" && hasAttr(p, "disabled") && child != firstLegend(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func firstLegend(fieldset *html.Node) *html.Node {
+	for c := fieldset.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "legend" {
+			return c
+		}
+	}
+	return nil
+}
+
+// Returns the autofill field name of the autocomplete attribute, like
+// "given-name" for autocomplete="section-1 shipping given-name". Section,
+// address type and contact type tokens are dropped.
+func getAutocomplete(n *html.Node) string {
+	tokens := strings.Fields(strings.ToLower(getAttr(n, "autocomplete")))
+	if len(tokens) > 0 && tokens[len(tokens)-1] == "webauthn" {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) == 0 {
+		return ""
+	}
+	return tokens[len(tokens)-1]
+}
+
+// The readonly attribute only applies to text-like inputs and textareas.
+func supportsReadOnly(element string, inputType string) bool {
+	switch element {
+	case ElementTextArea:
+		return true
+	case ElementInput:
+		switch inputType {
+		case InputTypeCheckbox, InputTypeRadio, InputTypeFile, InputTypeHidden,
+			InputTypeColor, InputTypeRange, InputTypeSubmit, InputTypeImage:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func createButton(n *html.Node) Button {
+	buttonType := InputTypeSubmit
+	text := getAttr(n, "value")
+	switch {
+	case n.Data == ElementButton:
+		text = collapseWhitespace(getText(n))
+	case getAttr(n, "type") == InputTypeImage:
+		buttonType = InputTypeImage
+		text = getAttr(n, "alt")
+	}
+	return Button{
+		Type:        buttonType,
+		Name:        getAttr(n, "name"),
+		Value:       getAttr(n, "value"),
+		ID:          getAttr(n, "id"),
+		Text:        text,
+		URL:         getAttr(n, "formaction"),
+		Method:      strings.ToUpper(getAttr(n, "formmethod")),
+		ContentType: getAttr(n, "formenctype"),
+		NoValidate:  hasAttr(n, "formnovalidate"),
+		Disabled:    isDisabled(n),
+		node:        n,
+	}
+}
+
 func getText(n *html.Node) string {
 	var b strings.Builder
 	var recursivelyGetText func(n *html.Node)
@@ -280,20 +536,58 @@ func getText(n *html.Node) string {
 	return b.String()
 }
 
-func findSelectOptions(n *html.Node) (values []string, options []string, ok bool) {
-	ok = true
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		if c.Type == html.ElementNode && c.Data == "option" && !hasAttr(c, "disabled") {
-			value := getAttr(c, "value")
-			options = append(options, value)
-			if hasAttr(c, "selected") {
-				values = append(values, value)
+// Finds options of a select element, including options in optgroups.
+// Disabled options (or options in disabled optgroups) are skipped. Single
+// selects submit the last selected option, or the first option when none is
+// selected, like browsers do.
+func findSelectOptions(n *html.Node) (values []string, options []string, labels []string) {
+	var addOptions func(n *html.Node)
+	addOptions = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || hasAttr(c, "disabled") {
+				continue
+			}
+			switch c.Data {
+			case "optgroup":
+				if n.Data == ElementSelect {
+					addOptions(c)
+				}
+			case "option":
+				text := collapseWhitespace(getText(c))
+				value, ok := getAttrOK(c, "value")
+				if !ok {
+					value = text
+				}
+				label := getAttr(c, "label")
+				if label == "" {
+					label = text
+				}
+				options = append(options, value)
+				labels = append(labels, label)
+				if hasAttr(c, "selected") {
+					values = append(values, value)
+				}
 			}
 		}
 	}
+	addOptions(n)
+
+	if hasAttr(n, "multiple") || atoi(getAttr(n, "size")) > 1 {
+		return
+	}
+	if len(values) > 1 {
+		values = values[len(values)-1:]
+	}
+	if len(values) == 0 && len(options) > 0 {
+		values = []string{options[0]}
+	}
 	return
 }
 
+func collapseWhitespace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
 func getAttr(n *html.Node, key string) (value string) {
 	value, _ = getAttrOK(n, key)
 	return
@@ -321,10 +615,84 @@ func getAttrOK(n *html.Node, key string) (value string, ok bool) {
 }
 
 func createTextInput(anyInput anyInput, n *html.Node) TextInput {
-	return TextInput{
+	i := TextInput{
+		anyInput:    anyInput,
+		pattern:     getPattern(n, nil),
+		placeholder: getAttr(n, "placeholder"),
+	}
+	i.minLength, i.hasMinLength = getLength(n, "minlength")
+	i.maxLength, i.hasMaxLength = getLength(n, "maxlength")
+	return i
+}
+
+// Parses a minlength or maxlength attribute. Returns false when the attribute
+// is missing, negative or not a number, which means there is no bound.
+func getLength(n *html.Node, key string) (int, bool) {
+	value, ok := getAttrOK(n, key)
+	if !ok {
+		return 0, false
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || length < 0 {
+		return 0, false
+	}
+	return length, true
+}
+
+func createNumberInput(anyInput anyInput, n *html.Node) NumberInput {
+	return NumberInput{
 		anyInput:  anyInput,
-		pattern:   getPattern(n, nil),
-		minLength: atoi(getAttr(n, "minlength")),
-		maxLength: atoi(getAttr(n, "maxlength")),
+		stepRange: createStepRange(n, parseNumber, 1, 1, 0),
+	}
+}
+
+// Parses min, max and step attributes. Attribute values are converted to
+// numbers using parse and the step is multiplied by stepScale. The step base
+// is the minimum, or the value when the minimum is missing.
+func createStepRange(
+	n *html.Node,
+	parse func(val string) (float64, bool),
+	defaultStep float64,
+	stepScale float64,
+	defaultStepBase float64,
+) (r stepRange) {
+	r.min, r.hasMin = parse(getAttr(n, "min"))
+	r.max, r.hasMax = parse(getAttr(n, "max"))
+	r.step = defaultStep * stepScale
+	step := getAttr(n, "step")
+	if strings.ToLower(step) == "any" {
+		r.step = 0
+	} else if value, ok := parseNumber(step); ok && value > 0 {
+		r.step = value * stepScale
+	}
+	r.stepBase = defaultStepBase
+	if r.hasMin {
+		r.stepBase = r.min
+	} else if value, ok := parse(getAttr(n, "value")); ok {
+		r.stepBase = value
+	}
+	return
+}
+
+// Creates a range input with the default minimum of 0 and maximum of 100. The
+// value is always set and kept within the range.
+func createRangeInput(anyInput anyInput, n *html.Node) RangeInput {
+	i := RangeInput{
+		NumberInput: createNumberInput(anyInput, n),
+	}
+	if !i.hasMin {
+		i.min, i.hasMin = 0, true
+	}
+	if !i.hasMax {
+		i.max, i.hasMax = 100, true
+	}
+	if i.max < i.min {
+		i.max = i.min
+	}
+	value, ok := parseNumber(i.Value())
+	if !ok {
+		value = i.min + (i.max-i.min)/2
 	}
+	i.values = []string{formatNumber(i.sanitize(value))}
+	return i
 }
@@ -2,18 +2,23 @@ package gosubmit
 
 import (
 	"fmt"
+	"math"
+	"net/url"
 	"regexp"
-	"strconv"
-	"time"
+	"strings"
+
+	"golang.org/x/net/html"
 )
 
 const (
 	AutoFillEmail = "test@example.com"
 	AutoFillURL   = "https://www.example.com"
-	ISO8601Date   = "2006-01-02"
-	AutoFillDate  = ISO8601Date
+	AutoFillTel   = "+15555550100"
+	AutoFillColor = defaultColor
 )
 
+const defaultColor = "#000000"
+
 var AutoFillFile = []byte{0xd, 0xe, 0xa, 0xd, 0xb, 0xe, 0xe, 0xf}
 
 type Input interface {
@@ -27,6 +32,20 @@ type Input interface {
 	Multiple() bool
 	Multipart() bool
 	AutoFill() []string
+	// Disabled fields are not submitted and cannot be filled.
+	Disabled() bool
+	// Readonly fields are submitted, but cannot be changed and are not
+	// validated.
+	ReadOnly() bool
+	// Returns the autofill field name from the autocomplete attribute, like
+	// "email" or "new-password". Empty when the attribute is missing.
+	Autocomplete() string
+}
+
+// Implemented by inputs which can describe why a value is invalid. The
+// returned error only has the Reason and Message set.
+type checker interface {
+	check(val string) (value string, err *FieldError)
 }
 
 type anyInput struct {
@@ -34,6 +53,10 @@ type anyInput struct {
 	inputType string
 	values    []string
 	required  bool
+	disabled  bool
+	readOnly  bool
+	// autofill field name from the autocomplete attribute
+	autocomplete string
 }
 
 func (i anyInput) Name() string {
@@ -63,6 +86,18 @@ func (i anyInput) Required() bool {
 	return i.required
 }
 
+func (i anyInput) Disabled() bool {
+	return i.disabled
+}
+
+func (i anyInput) ReadOnly() bool {
+	return i.readOnly
+}
+
+func (i anyInput) Autocomplete() string {
+	return i.autocomplete
+}
+
 func (i anyInput) Multiple() bool {
 	return false
 }
@@ -83,6 +118,10 @@ func (f FileInput) Fill(val string) (value string, ok bool) {
 	return "", false
 }
 
+func (f FileInput) check(val string) (string, *FieldError) {
+	return "", newFieldError(ReasonType, "file inputs can only be filled using AddFile")
+}
+
 func (f FileInput) Multipart() bool {
 	return true
 }
@@ -94,36 +133,104 @@ func (f FileInput) AutoFill() (values []string) {
 
 type TextInput struct {
 	anyInput
-	pattern   *regexp.Regexp
-	minLength int
-	maxLength int
+	pattern      *regexp.Regexp
+	minLength    int
+	maxLength    int
+	hasMinLength bool
+	hasMaxLength bool
+	placeholder  string
+}
+
+// Returns the value of the placeholder attribute.
+func (i TextInput) Placeholder() string {
+	return i.placeholder
 }
 
 func (i TextInput) Fill(val string) (value string, ok bool) {
-	length := len(val)
-	ok = i.minLength == 0 && i.maxLength == 0 || length >= i.minLength && length <= i.maxLength
+	value, err := i.check(val)
+	return value, err == nil
+}
+
+// Length and pattern are checked independently, so a value has to satisfy
+// both. Missing minlength or maxlength attributes mean there is no bound.
+// Like in browsers, empty values are not checked, only required fields have
+// to be filled.
+func (i TextInput) check(val string) (value string, err *FieldError) {
 	value = val
-	if i.pattern == nil {
+	if val == "" {
+		return
+	}
+	if err = i.checkLength(utf16Length(val)); err != nil {
 		return
 	}
-	ok = i.pattern.MatchString(value)
+	if i.pattern != nil && !i.pattern.MatchString(value) {
+		err = newFieldError(ReasonPattern, "value does not match pattern")
+	}
 	return
 }
 
-func (i TextInput) AutoFill() (value []string) {
-	length := 10
+func (i TextInput) AutoFill() []string {
+	return i.generate(newGenerator())
+}
+
+func (i TextInput) generate(g *generator) []string {
 	if i.pattern != nil {
-		return
+		value, ok := g.matchingString(i)
+		if !ok {
+			return nil
+		}
+		return []string{value}
 	}
-	if i.minLength > 0 {
+	length := 10
+	switch {
+	case i.hasMinLength && i.minLength > 0:
 		length = i.minLength
-		return []string{randomString(length)}
-	}
-	if i.maxLength > 0 {
+	case i.hasMaxLength:
 		length = i.maxLength
-		return []string{randomString(length)}
+	case !g.unicode:
+		return i.anyInput.AutoFill()
 	}
-	return i.anyInput.AutoFill()
+	return []string{g.randomString(length)}
+}
+
+// Textareas are validated like text inputs without a pattern. Their length is
+// measured in UTF-16 code units with line breaks counted as a single
+// character, and line breaks are submitted as CRLF.
+type TextArea struct {
+	TextInput
+	wrap string
+}
+
+// Returns the value of the wrap attribute: "soft" (default), "hard" or "off".
+func (i TextArea) Wrap() string {
+	return i.wrap
+}
+
+func (i TextArea) Fill(val string) (value string, ok bool) {
+	value, err := i.check(val)
+	return value, err == nil
+}
+
+func (i TextArea) check(val string) (value string, err *FieldError) {
+	value = val
+	if val == "" {
+		return
+	}
+	length := utf16Length(strings.Replace(normalizeNewlines(val), "\r\n", "\n", -1))
+	err = i.checkLength(length)
+	return
+}
+
+// Returns an error if the length is out of bounds. Missing bounds are
+// ignored.
+func (i TextInput) checkLength(length int) *FieldError {
+	switch {
+	case i.hasMinLength && length < i.minLength:
+		return newFieldError(ReasonMinLength, "value is shorter than minlength %d", i.minLength)
+	case i.hasMaxLength && length > i.maxLength:
+		return newFieldError(ReasonMaxLength, "value is longer than maxlength %d", i.maxLength)
+	}
+	return nil
 }
 
 type HiddenInput struct {
@@ -134,6 +241,15 @@ func (i HiddenInput) Fill(val string) (value string, ok bool) {
 	return i.Value(), false
 }
 
+// Hidden inputs can only be changed using ForceSet or ForceAdd.
+func (i HiddenInput) check(val string) (string, *FieldError) {
+	return val, nil
+}
+
+func (i HiddenInput) ReadOnly() bool {
+	return true
+}
+
 type inputWithOptions struct {
 	anyInput
 	options  []string
@@ -149,14 +265,17 @@ func (i inputWithOptions) Multiple() bool {
 }
 
 func (i inputWithOptions) Fill(val string) (value string, ok bool) {
-	ok = false
+	value, err := i.check(val)
+	return value, err == nil
+}
+
+func (i inputWithOptions) check(val string) (value string, err *FieldError) {
 	for _, opt := range i.options {
 		if opt == val {
-			value = val
-			ok = true
+			return val, nil
 		}
 	}
-	return
+	return "", newFieldError(ReasonNotAnOption, "value is not one of the options %q", i.options)
 }
 
 func (i inputWithOptions) AutoFill() (values []string) {
@@ -173,6 +292,21 @@ type EmailInput struct {
 	TextInput
 }
 
+func (i EmailInput) Fill(val string) (value string, ok bool) {
+	value, err := i.check(val)
+	return value, err == nil
+}
+
+func (i EmailInput) check(val string) (string, *FieldError) {
+	return checkType(i.TextInput, val, "value is not an email address")
+}
+
+// Email, URL and tel inputs override the random text generated for the
+// embedded TextInput.
+func (i EmailInput) generate(g *generator) []string {
+	return i.AutoFill()
+}
+
 func (i EmailInput) AutoFill() []string {
 	return []string{AutoFillEmail}
 }
@@ -181,45 +315,203 @@ type URLInput struct {
 	TextInput
 }
 
+func (i URLInput) Fill(val string) (value string, ok bool) {
+	value, err := i.check(val)
+	return value, err == nil
+}
+
+func (i URLInput) check(val string) (string, *FieldError) {
+	return checkType(i.TextInput, val, "value is not an absolute URL")
+}
+
+// Checks the value using the pattern of the text input and reports a type
+// mismatch if it does not match.
+func checkType(i TextInput, val string, message string) (value string, err *FieldError) {
+	value, err = i.check(val)
+	if err != nil && err.Reason == ReasonPattern {
+		err = newFieldError(ReasonType, message)
+	}
+	return
+}
+
+func (i URLInput) generate(g *generator) []string {
+	return i.AutoFill()
+}
+
 func (i URLInput) AutoFill() []string {
 	return []string{AutoFillURL}
 }
 
+// Min, max and step constraints of number, range, date and time inputs. All
+// values are numbers: date and time values are converted to milliseconds (or
+// months for month inputs) like the HTML spec describes.
+type stepRange struct {
+	min    float64
+	max    float64
+	hasMin bool
+	hasMax bool
+	// step is zero when any value is allowed (step="any")
+	step     float64
+	stepBase float64
+}
+
+func (r stepRange) inRange(number float64) bool {
+	return (!r.hasMin || number >= r.min) && (!r.hasMax || number <= r.max)
+}
+
+const stepEpsilon = 1e-9
+
+func (r stepRange) matchesStep(number float64) bool {
+	if r.step == 0 {
+		return true
+	}
+	steps := (number - r.stepBase) / r.step
+	return math.Abs(steps-math.Round(steps)) < stepEpsilon*math.Max(1, math.Abs(steps))
+}
+
+// Returns an error describing the violated constraint, if any. Bounds are
+// formatted using format.
+func (r stepRange) checkRange(number float64, format func(number float64) string) *FieldError {
+	switch {
+	case r.hasMin && number < r.min:
+		return newFieldError(ReasonMin, "value is less than min '%s'", format(r.min))
+	case r.hasMax && number > r.max:
+		return newFieldError(ReasonMax, "value is greater than max '%s'", format(r.max))
+	case !r.matchesStep(number):
+		return newFieldError(ReasonStep, "value does not match step")
+	}
+	return nil
+}
+
+// Returns the closest number to value which satisfies all constraints,
+// preferring larger numbers. Returns false if there is no such number.
+func (r stepRange) closest(value float64) (float64, bool) {
+	if r.hasMin && value < r.min {
+		value = r.min
+	}
+	if r.hasMax && value > r.max {
+		value = r.max
+	}
+	if r.step != 0 {
+		steps := math.Ceil((value-r.stepBase)/r.step - stepEpsilon)
+		value = roundNumber(r.stepBase + steps*r.step)
+		if r.hasMax && value > r.max {
+			value = roundNumber(value - r.step)
+		}
+	}
+	return value, r.inRange(value)
+}
+
+// Number and range inputs accept floating point numbers. Values have to be
+// within min and max (when set) and match the step, which is 1 by default.
 type NumberInput struct {
 	anyInput
-	min int
-	max int
+	stepRange
 }
 
+// Returns a value which satisfies the min, max and step constraints. The
+// value closest to zero is preferred.
 func (i NumberInput) AutoFill() []string {
-	return []string{itoa(i.min)}
+	value, ok := i.closest(0)
+	if !ok {
+		return nil
+	}
+	return []string{formatNumber(value)}
 }
 
 func (i NumberInput) Fill(val string) (value string, ok bool) {
-	ok = false
-	intValue, err := strconv.Atoi(val)
-	if err != nil {
+	value, err := i.check(val)
+	return value, err == nil
+}
+
+func (i NumberInput) check(val string) (value string, err *FieldError) {
+	number, ok := parseNumber(val)
+	if !ok {
+		err = newFieldError(ReasonType, "value is not a number")
 		return
 	}
-
-	ok = i.min == 0 && i.max == 0 || intValue >= i.min && intValue <= i.max
-	value = itoa(intValue)
+	value = formatNumber(number)
+	err = i.checkRange(number, formatNumber)
 	return
 }
 
-type DateInput struct {
+// Range inputs always have a value. When the value attribute is missing the
+// value is halfway between min and max.
+type RangeInput struct {
+	NumberInput
+}
+
+func (i RangeInput) AutoFill() []string {
+	return []string{i.Value()}
+}
+
+// Returns the number closest to value which satisfies all constraints of the
+// range, preferring the larger one when two are equally close.
+func (i RangeInput) sanitize(value float64) float64 {
+	if value < i.min {
+		value = i.min
+	}
+	if value > i.max {
+		value = i.max
+	}
+	if i.step == 0 {
+		return value
+	}
+	steps := math.Floor((value-i.stepBase)/i.step + 0.5 + stepEpsilon)
+	value = roundNumber(i.stepBase + steps*i.step)
+	if value > i.max {
+		value = roundNumber(value - i.step)
+	}
+	if value < i.min {
+		value = roundNumber(value + i.step)
+	}
+	return value
+}
+
+type TelInput struct {
+	TextInput
+}
+
+func (i TelInput) generate(g *generator) []string {
+	return i.AutoFill()
+}
+
+func (i TelInput) AutoFill() []string {
+	return []string{AutoFillTel}
+}
+
+type SearchInput struct {
+	TextInput
+}
+
+type PasswordInput struct {
+	TextInput
+}
+
+// Color inputs always have a value, in the #rrggbb format. Invalid values
+// fall back to #000000.
+type ColorInput struct {
 	anyInput
 }
 
-func (i DateInput) Fill(val string) (value string, ok bool) {
-	value = val
-	_, err := time.Parse(ISO8601Date, val)
-	ok = err == nil
+var patternColor = regexp.MustCompile("^#[0-9a-fA-F]{6}$")
+
+func (i ColorInput) Fill(val string) (value string, ok bool) {
+	value, err := i.check(val)
+	return value, err == nil
+}
+
+func (i ColorInput) check(val string) (value string, err *FieldError) {
+	if !patternColor.MatchString(val) {
+		err = newFieldError(ReasonType, "value is not a color in #rrggbb format")
+		return
+	}
+	value = strings.ToLower(val)
 	return
 }
 
-func (i DateInput) AutoFill() []string {
-	return []string{AutoFillDate}
+func (i ColorInput) AutoFill() []string {
+	return []string{AutoFillColor}
 }
 
 type Checkbox struct {
@@ -232,9 +524,39 @@ type Radio struct {
 
 type Select struct {
 	inputWithOptions
+	labels []string
+}
+
+// Returns the visible text (or label attribute) of each option, in the same
+// order as Options.
+func (i Select) Labels() []string {
+	return i.labels
 }
 
 type Button struct {
+	// Either InputTypeSubmit or InputTypeImage.
+	Type  string
 	Name  string
 	Value string
+	ID    string
+	// Visible text of the button. For <input type="submit"> elements it is
+	// the same as the value, and for <input type="image"> the alt text.
+	Text string
+	// Value of formaction attribute, resolved like the form URL. Overrides the
+	// form URL when set.
+	URL string
+	// Same as URL. Nil when the formaction attribute is missing.
+	Action *url.URL
+	// Value of formmethod attribute. Overrides the form method when set.
+	Method string
+	// Value of formenctype attribute. Overrides the form content type when
+	// set.
+	ContentType string
+	// True when the button has the formnovalidate attribute. Required fields
+	// are not checked when the form is submitted using this button.
+	NoValidate bool
+	// Disabled buttons cannot be clicked.
+	Disabled bool
+	// used to submit the value in tree order
+	node *html.Node
 }
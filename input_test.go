@@ -1,6 +1,9 @@
 package gosubmit
 
 import (
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -36,3 +39,229 @@ func Test_anyinput(t *testing.T) {
 		t.Errorf("a.Options() should always return 0 form this type but got %d", size)
 	}
 }
+
+func TestFill_html5_types(t *testing.T) {
+	for _, test := range []struct {
+		input    Input
+		value    string
+		expected string
+		ok       bool
+	}{
+		{TimeInput{}, "09:30", "09:30", true},
+		{TimeInput{}, "09:30:00", "09:30", true},
+		{TimeInput{}, "09:30:15.500", "09:30:15.5", true},
+		{TimeInput{}, "9:30", "", false},
+		{TimeInput{}, "24:00", "", false},
+		{DateTimeLocalInput{}, "2020-02-29T10:00", "2020-02-29T10:00", true},
+		{DateTimeLocalInput{}, "2020-02-29 10:00:30", "2020-02-29T10:00:30", true},
+		{DateTimeLocalInput{}, "2021-02-29T10:00", "", false},
+		{MonthInput{}, "2020-12", "2020-12", true},
+		{MonthInput{}, "2020-13", "", false},
+		{WeekInput{}, "2020-W53", "2020-W53", true},
+		{WeekInput{}, "2021-W53", "", false},
+		{WeekInput{}, "2021-W00", "", false},
+		{DateInput{}, "2020-01-02", "2020-01-02", true},
+		{DateInput{}, "2020-1-2", "", false},
+		{ColorInput{}, "#FFaa00", "#ffaa00", true},
+		{ColorInput{}, "red", "", false},
+		{TelInput{}, "+385 1 234", "+385 1 234", true},
+		{SearchInput{}, "query", "query", true},
+		{PasswordInput{}, "secret", "secret", true},
+	} {
+		value, ok := test.input.Fill(test.value)
+		if ok != test.ok || ok && value != test.expected {
+			t.Errorf("%T.Fill(%q): expected (%q, %t) but got (%q, %t)",
+				test.input, test.value, test.expected, test.ok, value, ok)
+		}
+	}
+}
+
+func TestWeekFormat(t *testing.T) {
+	start, ok := weekFormat.parse("2021-W01")
+	if !ok {
+		t.Fatalf("Expected week to be parsed")
+	}
+	if date := start.Format(ISO8601Date); date != "2021-01-04" {
+		t.Errorf("Expected week to start on 2021-01-04, but got %s", date)
+	}
+	if week := weekFormat.format(start.AddDate(0, 0, 6)); week != "2021-W01" {
+		t.Errorf("Expected sunday to be in week 2021-W01, but got %s", week)
+	}
+}
+
+func TestFromUnixMilliseconds(t *testing.T) {
+	for _, value := range []string{"0001-01-01T00:00", "1600-01-01T12:30:15.5", "1969-12-31T23:59:59.999", "2500-01-01T00:00", "9999-12-31T23:59"} {
+		number, ok := dateTimeLocalFormat.parseNumber(value)
+		if !ok {
+			t.Fatalf("Expected %s to be parsed", value)
+		}
+		if formatted := dateTimeLocalFormat.formatNumber(number); formatted != value {
+			t.Errorf("Expected %s, but got %s", value, formatted)
+		}
+	}
+}
+
+func TestNumberInput(t *testing.T) {
+	doc := Parse(strings.NewReader(`<!DOCTYPE html>
+<form>
+  <input type="number" name="price" min="0" step="0.01">
+  <input type="number" name="negative" min="-10" max="-2" step="4">
+  <input type="number" name="zero" min="0" max="0">
+  <input type="number" name="any" step="any" max="-0.5">
+  <input type="number" name="odd" value="1" step="2">
+  <input type="range" name="slider" min="0" max="1" step="0.1" value="0.25">
+</form>`))
+	form := doc.FirstForm()
+
+	for _, test := range []struct {
+		name     string
+		value    string
+		expected string
+		ok       bool
+	}{
+		{"price", "19.99", "19.99", true},
+		{"price", "0.001", "", false},
+		{"price", "-1", "", false},
+		{"price", "1e2", "100", true},
+		{"price", "abc", "", false},
+		{"price", "Infinity", "", false},
+		{"negative", "-6", "-6", true},
+		{"negative", "-4", "", false},
+		{"negative", "-11", "", false},
+		{"zero", "0", "0", true},
+		{"zero", "1", "", false},
+		{"any", "-0.723", "-0.723", true},
+		{"any", "0", "", false},
+		{"odd", "5", "5", true},
+		{"odd", "4", "", false},
+		{"slider", "0.7", "0.7", true},
+		{"slider", "0.75", "", false},
+	} {
+		value, ok := form.Inputs[test.name].Fill(test.value)
+		if ok != test.ok || ok && value != test.expected {
+			t.Errorf("%s.Fill(%q): expected (%q, %t) but got (%q, %t)",
+				test.name, test.value, test.expected, test.ok, value, ok)
+		}
+	}
+
+	for name, expected := range map[string][]string{
+		"price":    {"0"},
+		"negative": {"-2"},
+		"zero":     {"0"},
+		"any":      {"-0.5"},
+		"odd":      {"1"},
+		"slider":   {"0.3"},
+	} {
+		if values := form.Inputs[name].AutoFill(); !reflect.DeepEqual(expected, values) {
+			t.Errorf("%s.AutoFill(): expected %v but got %v", name, expected, values)
+		}
+	}
+}
+
+func TestTextInput_utf16Length(t *testing.T) {
+	i := TextInput{minLength: 2, maxLength: 5, hasMinLength: true, hasMaxLength: true}
+	for _, test := range []struct {
+		value string
+		ok    bool
+	}{
+		{"ćčžšđ", true},
+		{"ćčžšđž", false},
+		{"😀😀", true},
+		{"😀😀😀", false},
+		{"😀", true},
+		{"a", false},
+	} {
+		if _, ok := i.Fill(test.value); ok != test.ok {
+			t.Errorf("Expected Fill('%s') to return %t", test.value, test.ok)
+		}
+	}
+}
+
+func TestGenerator_randomString(t *testing.T) {
+	g := newGenerator()
+	g.unicode = true
+	for length := 0; length < 20; length++ {
+		value := g.randomString(length)
+		if l := utf16Length(value); l != length {
+			t.Errorf("Expected '%s' to be %d UTF-16 code units long, but was %d", value, length, l)
+		}
+	}
+	value := g.randomString(100)
+	if strings.Trim(value, letters) == "" {
+		t.Errorf("Expected non-ASCII characters in '%s'", value)
+	}
+}
+
+func TestTextInput_lengthAndPattern(t *testing.T) {
+	for _, test := range []struct {
+		input  TextInput
+		value  string
+		reason Reason
+	}{
+		{TextInput{minLength: 3, hasMinLength: true}, "abcdefghijklmnop", ""},
+		{TextInput{minLength: 3, hasMinLength: true}, "ab", ReasonMinLength},
+		{TextInput{minLength: 3, hasMinLength: true}, "", ""},
+		{TextInput{maxLength: 3, hasMaxLength: true}, "", ""},
+		{TextInput{maxLength: 3, hasMaxLength: true}, "abcd", ReasonMaxLength},
+		{TextInput{maxLength: 0, hasMaxLength: true}, "a", ReasonMaxLength},
+		{TextInput{maxLength: 0}, "a", ""},
+		{TextInput{pattern: regexp.MustCompile("^[a-z]+$")}, "", ""},
+		{TextInput{minLength: 2, maxLength: 3, hasMinLength: true, hasMaxLength: true, pattern: regexp.MustCompile("^[a-z]+$")}, "abcd", ReasonMaxLength},
+		{TextInput{minLength: 2, maxLength: 3, hasMinLength: true, hasMaxLength: true, pattern: regexp.MustCompile("^[a-z]+$")}, "a", ReasonMinLength},
+		{TextInput{minLength: 2, maxLength: 3, hasMinLength: true, hasMaxLength: true, pattern: regexp.MustCompile("^[a-z]+$")}, "AB", ReasonPattern},
+		{TextInput{minLength: 2, maxLength: 3, hasMinLength: true, hasMaxLength: true, pattern: regexp.MustCompile("^[a-z]+$")}, "ab", ""},
+	} {
+		_, err := test.input.check(test.value)
+		var reason Reason
+		if err != nil {
+			reason = err.Reason
+		}
+		if reason != test.reason {
+			t.Errorf("Expected '%s' to fail with '%s', but got '%s'", test.value, test.reason, reason)
+		}
+	}
+
+	g := newGenerator()
+	for _, input := range []TextInput{
+		{minLength: 12, hasMinLength: true},
+		{maxLength: 4, hasMaxLength: true},
+		{minLength: 2, maxLength: 20, hasMinLength: true, hasMaxLength: true},
+	} {
+		values := input.generate(g)
+		if len(values) != 1 {
+			t.Fatalf("Expected a single value, but got %v", values)
+		}
+		if _, err := input.check(values[0]); err != nil {
+			t.Errorf("Expected generated value '%s' to be valid, but got %s", values[0], err)
+		}
+	}
+}
+
+func TestGuessKind(t *testing.T) {
+	for name, kind := range map[string]string{
+		"first_name":       fakeFirstName,
+		"firstName":        fakeFirstName,
+		"user[first-name]": fakeFirstName,
+		"lname":            fakeLastName,
+		"surname":          fakeLastName,
+		"fullname":         fakeName,
+		"full_name":        fakeName,
+		"e-mail":           fakeEmail,
+		"emailAddress":     fakeEmail,
+		"user_name":        fakeUsername,
+		"phoneNumber":      fakeTel,
+		"hotel":            "",
+		"zip_code":         fakePostcode,
+		"address_line1":    fakeStreet,
+		"companyName":      fakeCompany,
+		"websiteURL":       fakeURL,
+		"URLField":         fakeURL,
+		"comment":          "",
+	} {
+		input := TextInput{}
+		input.name = name
+		if guessed := guessKind(input); guessed != kind {
+			t.Errorf("Expected kind of %s to be '%s', but was '%s'", name, kind, guessed)
+		}
+	}
+}
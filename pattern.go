@@ -0,0 +1,124 @@
+package gosubmit
+
+import (
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// Number of attempts to generate a value which matches both the pattern and
+// the length constraints.
+const patternAttempts = 200
+
+// Returns a random string which matches the pattern of the input and
+// satisfies its minlength and maxlength constraints. Returns false when no
+// such string was found.
+func (g *generator) matchingString(i TextInput) (string, bool) {
+	pattern := i.pattern
+	re, err := syntax.Parse(pattern.String(), syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	re = re.Simplify()
+	for attempt := 0; attempt < patternAttempts; attempt++ {
+		// Unbounded repetitions like + and * are repeated more often with
+		// each attempt, until the value is long enough.
+		p := patternGenerator{generator: g, extra: 1 + attempt/4}
+		p.generate(re)
+		if p.impossible {
+			return "", false
+		}
+		value := p.b.String()
+		if i.checkLength(utf16Length(value)) == nil && pattern.MatchString(value) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+type patternGenerator struct {
+	*generator
+	b strings.Builder
+	// maximum number of additional repetitions of unbounded repeats
+	extra int
+	// set when the pattern contains something which cannot match, like an
+	// empty character class
+	impossible bool
+}
+
+func (p *patternGenerator) generate(re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			p.b.WriteRune(r)
+		}
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			p.impossible = true
+			return
+		}
+		p.b.WriteRune(p.pickRune(re.Rune))
+	case syntax.OpNoMatch:
+		p.impossible = true
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		p.b.WriteByte(letters[p.rand.Intn(len(letters))])
+	case syntax.OpCapture:
+		p.generate(re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			p.generate(sub)
+		}
+	case syntax.OpAlternate:
+		p.generate(re.Sub[p.rand.Intn(len(re.Sub))])
+	case syntax.OpStar:
+		p.repeat(re.Sub[0], 0, -1)
+	case syntax.OpPlus:
+		p.repeat(re.Sub[0], 1, -1)
+	case syntax.OpQuest:
+		p.repeat(re.Sub[0], 0, 1)
+	case syntax.OpRepeat:
+		p.repeat(re.Sub[0], re.Min, re.Max)
+	}
+	// Other operators like ^, $ and \b do not match any characters.
+}
+
+// Repeats re between min and max times. Max is -1 for unbounded repeats.
+func (p *patternGenerator) repeat(re *syntax.Regexp, min int, max int) {
+	if max < 0 {
+		max = min + p.extra
+	}
+	count := min + p.rand.Intn(max-min+1)
+	for i := 0; i < count; i++ {
+		p.generate(re)
+	}
+}
+
+// Picks a random rune from pairs of rune ranges. Letters and digits are
+// preferred, followed by other printable ASCII characters, so negated
+// classes like [^@] do not produce control characters.
+func (p *patternGenerator) pickRune(ranges []rune) rune {
+	for _, preferred := range []func(r rune) bool{isAlphanumeric, isPrintableASCII} {
+		var candidates []rune
+		for i := 0; i+1 < len(ranges); i += 2 {
+			for r := ranges[i]; r <= ranges[i+1] && r < unicode.MaxASCII; r++ {
+				if preferred(r) {
+					candidates = append(candidates, r)
+				}
+			}
+		}
+		if len(candidates) > 0 {
+			return candidates[p.rand.Intn(len(candidates))]
+		}
+	}
+	i := p.rand.Intn(len(ranges)/2) * 2
+	lo, hi := ranges[i], ranges[i+1]
+	return lo + rune(p.rand.Int63n(int64(hi-lo)+1))
+}
+
+func isAlphanumeric(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+func isPrintableASCII(r rune) bool {
+	return r >= ' ' && r <= '~'
+}
@@ -0,0 +1,438 @@
+package gosubmit
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// A compiled CSS selector. Supports type, universal, id, class and attribute
+// selectors, the :first-child, :last-child, :only-child and :not() pseudo
+// classes, all four combinators and selector lists separated by commas.
+type selector []complexSelector
+
+// Compound selectors in order of appearance, separated by combinators.
+// combinators[i] is placed between compounds[i] and compounds[i+1].
+type complexSelector struct {
+	compounds   []compoundSelector
+	combinators []byte
+}
+
+type compoundSelector []func(n *html.Node) bool
+
+func (s selector) match(n *html.Node) bool {
+	if n == nil || n.Type != html.ElementNode {
+		return false
+	}
+	for _, c := range s {
+		if c.match(n, len(c.compounds)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c complexSelector) match(n *html.Node, index int) bool {
+	if !c.compounds[index].match(n) {
+		return false
+	}
+	if index == 0 {
+		return true
+	}
+	switch c.combinators[index-1] {
+	case '>':
+		p := n.Parent
+		return p != nil && p.Type == html.ElementNode && c.match(p, index-1)
+	case '+':
+		p := previousElement(n)
+		return p != nil && c.match(p, index-1)
+	case '~':
+		for p := previousElement(n); p != nil; p = previousElement(p) {
+			if c.match(p, index-1) {
+				return true
+			}
+		}
+	default:
+		for p := n.Parent; p != nil; p = p.Parent {
+			if p.Type == html.ElementNode && c.match(p, index-1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c compoundSelector) match(n *html.Node) bool {
+	for _, matches := range c {
+		if !matches(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func previousElement(n *html.Node) *html.Node {
+	for p := n.PrevSibling; p != nil; p = p.PrevSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+func nextElement(n *html.Node) *html.Node {
+	for p := n.NextSibling; p != nil; p = p.NextSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+func compileSelector(s string) (selector, error) {
+	p := selectorParser{input: s}
+	sel, err := p.parseSelectorList()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid selector '%s': %w", s, err)
+	}
+	return sel, nil
+}
+
+type selectorParser struct {
+	input string
+	pos   int
+}
+
+func (p *selectorParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *selectorParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *selectorParser) skipWhitespace() (skipped bool) {
+	for !p.eof() && isSelectorWhitespace(p.peek()) {
+		p.pos++
+		skipped = true
+	}
+	return
+}
+
+func isSelectorWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func (p *selectorParser) parseSelectorList() (sel selector, err error) {
+	for {
+		p.skipWhitespace()
+		c, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, c)
+		p.skipWhitespace()
+		if p.eof() {
+			return sel, nil
+		}
+		if p.peek() != ',' {
+			return nil, fmt.Errorf("unexpected '%c' at position %d", p.peek(), p.pos)
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) parseComplex() (c complexSelector, err error) {
+	compound, err := p.parseCompound()
+	if err != nil {
+		return
+	}
+	c.compounds = append(c.compounds, compound)
+	for {
+		skipped := p.skipWhitespace()
+		if p.eof() || p.peek() == ',' || p.peek() == ')' {
+			return
+		}
+		combinator := byte(' ')
+		switch p.peek() {
+		case '>', '+', '~':
+			combinator = p.peek()
+			p.pos++
+			p.skipWhitespace()
+		default:
+			if !skipped {
+				err = fmt.Errorf("unexpected '%c' at position %d", p.peek(), p.pos)
+				return
+			}
+		}
+		compound, err = p.parseCompound()
+		if err != nil {
+			return
+		}
+		c.combinators = append(c.combinators, combinator)
+		c.compounds = append(c.compounds, compound)
+	}
+}
+
+func (p *selectorParser) parseCompound() (c compoundSelector, err error) {
+	start := p.pos
+	if p.peek() == '*' {
+		p.pos++
+	} else if isIdentStart(p.peek()) {
+		tag := strings.ToLower(p.parseIdent())
+		c = append(c, func(n *html.Node) bool {
+			return n.Data == tag
+		})
+	}
+	for !p.eof() {
+		var m func(n *html.Node) bool
+		switch p.peek() {
+		case '#':
+			p.pos++
+			id, e := p.expectIdent()
+			if e != nil {
+				return nil, e
+			}
+			m = func(n *html.Node) bool {
+				return getAttr(n, "id") == id
+			}
+		case '.':
+			p.pos++
+			class, e := p.expectIdent()
+			if e != nil {
+				return nil, e
+			}
+			m = func(n *html.Node) bool {
+				return includesWord(getAttr(n, "class"), class)
+			}
+		case '[':
+			p.pos++
+			m, err = p.parseAttribute()
+		case ':':
+			p.pos++
+			m, err = p.parsePseudo()
+		default:
+			if p.pos == start {
+				return nil, fmt.Errorf("expected selector at position %d", p.pos)
+			}
+			return
+		}
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, m)
+	}
+	if p.pos == start {
+		return nil, fmt.Errorf("expected selector at position %d", p.pos)
+	}
+	return
+}
+
+func (p *selectorParser) parseAttribute() (m func(n *html.Node) bool, err error) {
+	p.skipWhitespace()
+	key, err := p.expectIdent()
+	if err != nil {
+		return
+	}
+	key = strings.ToLower(key)
+	p.skipWhitespace()
+	if p.peek() == ']' {
+		p.pos++
+		m = func(n *html.Node) bool {
+			return hasAttr(n, key)
+		}
+		return
+	}
+
+	op := ""
+	switch p.peek() {
+	case '=':
+		op = "="
+		p.pos++
+	case '~', '|', '^', '$', '*':
+		op = p.input[p.pos : p.pos+1]
+		p.pos++
+		if p.peek() != '=' {
+			err = fmt.Errorf("expected '=' at position %d", p.pos)
+			return
+		}
+		p.pos++
+	default:
+		err = fmt.Errorf("unexpected '%c' in attribute selector at position %d", p.peek(), p.pos)
+		return
+	}
+	p.skipWhitespace()
+
+	var value string
+	if c := p.peek(); c == '"' || c == '\'' {
+		value, err = p.parseString()
+	} else {
+		value, err = p.expectIdent()
+	}
+	if err != nil {
+		return
+	}
+	p.skipWhitespace()
+	if p.peek() != ']' {
+		err = fmt.Errorf("expected ']' at position %d", p.pos)
+		return
+	}
+	p.pos++
+
+	var matchValue func(attr string) bool
+	switch op {
+	case "=":
+		matchValue = func(attr string) bool { return attr == value }
+	case "~":
+		matchValue = func(attr string) bool { return includesWord(attr, value) }
+	case "|":
+		matchValue = func(attr string) bool {
+			return attr == value || strings.HasPrefix(attr, value+"-")
+		}
+	case "^":
+		matchValue = func(attr string) bool { return value != "" && strings.HasPrefix(attr, value) }
+	case "$":
+		matchValue = func(attr string) bool { return value != "" && strings.HasSuffix(attr, value) }
+	case "*":
+		matchValue = func(attr string) bool { return value != "" && strings.Contains(attr, value) }
+	}
+	m = func(n *html.Node) bool {
+		attr, ok := getAttrOK(n, key)
+		return ok && matchValue(attr)
+	}
+	return
+}
+
+func (p *selectorParser) parsePseudo() (m func(n *html.Node) bool, err error) {
+	name, err := p.expectIdent()
+	if err != nil {
+		return
+	}
+	switch strings.ToLower(name) {
+	case "first-child":
+		m = func(n *html.Node) bool {
+			return previousElement(n) == nil
+		}
+	case "last-child":
+		m = func(n *html.Node) bool {
+			return nextElement(n) == nil
+		}
+	case "only-child":
+		m = func(n *html.Node) bool {
+			return previousElement(n) == nil && nextElement(n) == nil
+		}
+	case "not":
+		if p.peek() != '(' {
+			err = fmt.Errorf("expected '(' at position %d", p.pos)
+			return
+		}
+		p.pos++
+		var not selector
+		for {
+			p.skipWhitespace()
+			compound, e := p.parseCompound()
+			if e != nil {
+				return nil, e
+			}
+			not = append(not, complexSelector{compounds: []compoundSelector{compound}})
+			p.skipWhitespace()
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+		if p.peek() != ')' {
+			err = fmt.Errorf("expected ')' at position %d", p.pos)
+			return
+		}
+		p.pos++
+		m = func(n *html.Node) bool {
+			return !not.match(n)
+		}
+	default:
+		err = fmt.Errorf("unsupported pseudo-class ':%s'", name)
+	}
+	return
+}
+
+func (p *selectorParser) parseString() (value string, err error) {
+	quote := p.peek()
+	p.pos++
+	var b strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		switch c {
+		case quote:
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			p.pos++
+			if p.eof() {
+				break
+			}
+			r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	err = fmt.Errorf("unterminated string at position %d", p.pos)
+	return
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '-' || c == '\\' || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
+
+func (p *selectorParser) expectIdent() (string, error) {
+	if !isIdentChar(p.peek()) {
+		if p.eof() {
+			return "", fmt.Errorf("unexpected end of selector")
+		}
+		return "", fmt.Errorf("expected identifier at position %d", p.pos)
+	}
+	return p.parseIdent(), nil
+}
+
+func (p *selectorParser) parseIdent() string {
+	var b strings.Builder
+	for !p.eof() && isIdentChar(p.peek()) {
+		c := p.peek()
+		if c == '\\' {
+			p.pos++
+			if p.eof() {
+				break
+			}
+			r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+	return b.String()
+}
+
+func includesWord(list string, word string) bool {
+	if word == "" {
+		return false
+	}
+	for _, w := range strings.Fields(list) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package gosubmit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+)
+
+const maxRedirects = 10
+
+// Initial URL of a session. The host is the same one httptest.NewRequest
+// uses.
+const sessionURL = "http://example.com/"
+
+// Session behaves like a simple browser. It keeps cookies between requests,
+// follows redirects and remembers the last page so forms can be submitted
+// one after another.
+type Session struct {
+	handler  http.Handler
+	client   *http.Client
+	jar      http.CookieJar
+	url      *url.URL
+	response *http.Response
+	document Document
+}
+
+// Creates a new session which serves all requests using handler.
+func NewSession(handler http.Handler) *Session {
+	s := newSession()
+	s.handler = handler
+	return s
+}
+
+// Creates a new session which sends all requests using client. The cookie jar
+// of the client is used when set, otherwise the session uses its own.
+func NewClientSession(client *http.Client) *Session {
+	s := newSession()
+	c := *client
+	if c.Jar == nil {
+		c.Jar = s.jar
+	}
+	s.client = &c
+	s.jar = c.Jar
+	return s
+}
+
+func newSession() *Session {
+	jar, _ := cookiejar.New(nil)
+	u, _ := url.Parse(sessionURL)
+	return &Session{
+		jar: jar,
+		url: u,
+	}
+}
+
+// Returns the last parsed page.
+func (s *Session) Document() Document {
+	return s.document
+}
+
+// Returns the last received response. The body has already been read.
+func (s *Session) Response() *http.Response {
+	return s.response
+}
+
+// Returns the URL of the current page.
+func (s *Session) URL() *url.URL {
+	return s.url
+}
+
+// Returns the cookie jar used by the session.
+func (s *Session) Jar() http.CookieJar {
+	return s.jar
+}
+
+// Opens a page. Relative URLs are resolved against the current page URL.
+func (s *Session) Get(rawurl string) (Document, error) {
+	u, err := s.url.Parse(rawurl)
+	if err != nil {
+		return Document{}, fmt.Errorf("Error parsing url '%s': %w", rawurl, err)
+	}
+	r, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Document{}, fmt.Errorf("Error creating get request: %w", err)
+	}
+	return s.do(r)
+}
+
+// Finds a form on the current page using a CSS selector, fills it and
+// submits it. An empty selector uses the first form. Returns the next page.
+func (s *Session) Submit(selector string, opts ...Option) (Document, error) {
+	form := s.findForm(selector)
+	r, err := form.NewRequest(opts...)
+	if err != nil {
+		return Document{}, err
+	}
+	return s.do(r)
+}
+
+func (s *Session) findForm(selector string) Form {
+	if selector == "" {
+		return s.document.FirstForm()
+	}
+	return s.document.FormBySelector(selector)
+}
+
+func (s *Session) do(r *http.Request) (doc Document, err error) {
+	var res *http.Response
+	if s.client != nil {
+		res, err = s.client.Do(r)
+	} else {
+		res, err = s.serve(r)
+	}
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	s.response = res
+	s.url = res.Request.URL
+	// cookies are kept in the jar of the session instead of the forms
+	s.document = parse(res.Body, s.url, res.Header.Get("Content-Type"))
+	return s.document, s.document.Err()
+}
+
+// Serves the request using the handler and follows any redirects, the same
+// way http.Client would.
+func (s *Session) serve(r *http.Request) (*http.Response, error) {
+	for redirects := 0; ; redirects++ {
+		for _, cookie := range s.jar.Cookies(r.URL) {
+			r.AddCookie(cookie)
+		}
+		r.RequestURI = r.URL.RequestURI()
+
+		w := httptest.NewRecorder()
+		s.handler.ServeHTTP(w, r)
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		res := w.Result()
+		res.Request = r
+
+		if cookies := res.Cookies(); len(cookies) > 0 {
+			s.jar.SetCookies(r.URL, cookies)
+		}
+
+		location := res.Header.Get("Location")
+		if !isRedirect(res.StatusCode) || location == "" {
+			return res, nil
+		}
+		if redirects == maxRedirects {
+			return nil, fmt.Errorf("Stopped after %d redirects", maxRedirects)
+		}
+		next, err := redirectRequest(r, res.StatusCode, location)
+		if err != nil {
+			return nil, err
+		}
+		r = next
+	}
+}
+
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently,
+		http.StatusFound,
+		http.StatusSeeOther,
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+func redirectRequest(r *http.Request, code int, location string) (*http.Request, error) {
+	u, err := r.URL.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing redirect location '%s': %w", location, err)
+	}
+	if code != http.StatusTemporaryRedirect && code != http.StatusPermanentRedirect {
+		method := http.MethodGet
+		if r.Method == http.MethodHead {
+			method = http.MethodHead
+		}
+		next, err := http.NewRequestWithContext(r.Context(), method, u.String(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating redirect request: %w", err)
+		}
+		return next, nil
+	}
+	// 307 and 308 require the same method and body to be sent again
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return nil, fmt.Errorf("Cannot follow redirect to '%s': request body cannot be sent again", location)
+	}
+	next, err := http.NewRequestWithContext(r.Context(), r.Method, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating redirect request: %w", err)
+	}
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading request body for redirect: %w", err)
+		}
+		next.Body = body
+		next.GetBody = r.GetBody
+		next.ContentLength = r.ContentLength
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		next.Header.Set("Content-Type", contentType)
+	}
+	return next, nil
+}
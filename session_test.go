@@ -0,0 +1,216 @@
+package gosubmit_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	. "github.com/jeremija/gosubmit"
+)
+
+func newWizard() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if r.FormValue("username") != "user" || r.FormValue("csrf") != "1234" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+			http.Redirect(w, r, "/wizard/step1", http.StatusSeeOther)
+			return
+		}
+		w.Write([]byte(`<!DOCTYPE html>
+<form id="login" method="POST">
+	<input type="text" name="username" required>
+	<input type="hidden" name="csrf" value="1234">
+</form>`))
+	})
+	mux.HandleFunc("/wizard/step1", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "s1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPost {
+			http.Redirect(w, r, "step2?color="+r.FormValue("color"), http.StatusFound)
+			return
+		}
+		w.Write([]byte(`<!DOCTYPE html>
+<form class="wizard" method="POST">
+	<select name="color"><option value="red">Red</option><option value="blue">Blue</option></select>
+</form>`))
+	})
+	mux.HandleFunc("/wizard/step2", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("session"); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("Done: " + r.FormValue("color")))
+	})
+	return mux
+}
+
+func TestSession_Submit(t *testing.T) {
+	s := NewSession(newWizard())
+
+	if _, err := s.Get("/login"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	_, err := s.Submit("#login", Set("username", "user"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if path := s.URL().Path; path != "/wizard/step1" {
+		t.Fatalf("Expected to be redirected to /wizard/step1, but was %s", path)
+	}
+
+	_, err = s.Submit(".wizard", Set("color", "blue"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if code := s.Response().StatusCode; code != http.StatusOK {
+		t.Fatalf("Expected status code %d, but got %d", http.StatusOK, code)
+	}
+	if u := s.URL().String(); u != "http://example.com/wizard/step2?color=blue" {
+		t.Errorf("Unexpected url: %s", u)
+	}
+}
+
+func TestSession_Submit_no_form(t *testing.T) {
+	s := NewSession(newWizard())
+
+	if _, err := s.Get("/login"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for selector, expected := range map[string]string{
+		"#missing":   "No form matching selector '#missing' found",
+		"div > form": "No form matching selector 'div > form' found",
+		"form[":      "Invalid selector 'form[': unexpected end of selector",
+	} {
+		_, err := s.Submit(selector)
+		if err == nil || err.Error() != expected {
+			t.Errorf("Expected error '%s', but got %s", expected, err)
+		}
+	}
+}
+
+func TestSession_Submit_forbidden(t *testing.T) {
+	s := NewSession(newWizard())
+
+	if _, err := s.Get("/login"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := s.Submit("", Set("username", "other")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if code := s.Response().StatusCode; code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, but got %d", http.StatusForbidden, code)
+	}
+}
+
+func TestSession_redirect_loop(t *testing.T) {
+	s := NewSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	}))
+
+	_, err := s.Get("/loop")
+	re := regexp.MustCompile("Stopped after 10 redirects")
+	if err == nil || !re.MatchString(err.Error()) {
+		t.Errorf("Expected error to match '%s', but got %s", re, err)
+	}
+}
+
+func TestClientSession(t *testing.T) {
+	server := httptest.NewServer(newWizard())
+	defer server.Close()
+
+	s := NewClientSession(server.Client())
+
+	if _, err := s.Get(server.URL + "/login"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := s.Submit("#login", Set("username", "user")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := s.Submit("", Set("color", "red")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if code := s.Response().StatusCode; code != http.StatusOK {
+		t.Fatalf("Expected status code %d, but got %d", http.StatusOK, code)
+	}
+	if q := s.URL().Query().Get("color"); q != "red" {
+		t.Errorf("Expected color=red in url, but got %s", s.URL())
+	}
+}
+
+func TestSession_Submit_unreadBody(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Write([]byte(`<form method="post" enctype="multipart/form-data"><input type="file" name="upload"></form>`))
+	})
+	s := NewSession(mux)
+	if _, err := s.Get("/upload"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	before := runtime.NumGoroutine()
+	_, err := s.Submit("", AddFileReader("upload", "large.bin", io.LimitReader(zeroReader{}, 1<<20), 1<<20))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if code := s.Response().StatusCode; code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, but got %d", code)
+	}
+	waitForGoroutines(t, before)
+}
+
+func TestSession_Submit_multipartRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			http.Redirect(w, r, "/v2/upload", http.StatusTemporaryRedirect)
+			return
+		}
+		w.Write([]byte(`<form method="post" enctype="multipart/form-data"><input type="file" name="upload"></form>`))
+	})
+	mux.HandleFunc("/v2/upload", func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("upload")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		contents, _ := ioutil.ReadAll(file)
+		w.Write([]byte(`<form><input name="got" value="` + header.Filename + ": " + string(contents) + `"></form>`))
+	})
+	s := NewSession(mux)
+	if _, err := s.Get("/upload"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	doc, err := s.Submit("", AddFile("upload", "a.txt", []byte("hello")))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got := doc.FirstForm().Inputs["got"].Value(); got != "a.txt: hello" {
+		t.Errorf("Expected the file to be sent again, but got '%s'", got)
+	}
+
+	s.Get("/upload")
+	_, err = s.Submit("", AddFileReader("upload", "a.txt", strings.NewReader("hello"), 5))
+	re := regexp.MustCompile("request body cannot be sent again")
+	if err == nil || !re.MatchString(err.Error()) {
+		t.Errorf("Expected error to match '%s', but was '%v'", re, err)
+	}
+}
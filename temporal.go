@@ -0,0 +1,406 @@
+package gosubmit
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	ISO8601Date           = "2006-01-02"
+	ISO8601Time           = "15:04"
+	ISO8601DateTimeLocal  = "2006-01-02T15:04"
+	ISO8601Month          = "2006-01"
+	AutoFillDate          = ISO8601Date
+	AutoFillTime          = ISO8601Time
+	AutoFillDateTimeLocal = ISO8601DateTimeLocal
+	AutoFillMonth         = ISO8601Month
+	AutoFillWeek          = "2006-W01"
+)
+
+// Parses and formats values of one of the date and time input types, as
+// described in the HTML spec. For min, max and step validation values are
+// converted to numbers: milliseconds since the epoch (or since midnight for
+// time inputs) and months since January 1970 for month inputs.
+type temporalFormat struct {
+	parse      func(val string) (t time.Time, ok bool)
+	format     func(t time.Time) string
+	toNumber   func(t time.Time) float64
+	fromNumber func(number float64) time.Time
+	// step attribute unit in milliseconds (or months)
+	stepScale       float64
+	defaultStep     float64
+	defaultStepBase float64
+}
+
+// Time used by AutoFill when there is no clock. All AutoFill constants are
+// formatted from this time.
+var autoFillTime = time.Date(2006, time.January, 2, 15, 4, 0, 0, time.UTC)
+
+const (
+	millisecondsPerDay  = 24 * 60 * 60 * 1000
+	millisecondsPerWeek = 7 * millisecondsPerDay
+)
+
+var (
+	patternDate          = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	patternTime          = regexp.MustCompile(`^\d{2}:\d{2}(:\d{2}(\.\d{1,3})?)?$`)
+	patternDateTimeLocal = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})[T ](\d{2}:\d{2}(:\d{2}(\.\d{1,3})?)?)$`)
+	patternMonth         = regexp.MustCompile(`^\d{4}-\d{2}$`)
+	patternWeek          = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+)
+
+var dateFormat = temporalFormat{
+	parse: func(val string) (time.Time, bool) {
+		return parseLayout(patternDate, ISO8601Date, val)
+	},
+	format: func(t time.Time) string {
+		return t.Format(ISO8601Date)
+	},
+	toNumber:    unixMilliseconds,
+	fromNumber:  fromUnixMilliseconds,
+	stepScale:   millisecondsPerDay,
+	defaultStep: 1,
+}
+
+var timeFormat = temporalFormat{
+	parse: func(val string) (time.Time, bool) {
+		return parseLayout(patternTime, "15:04:05", normalizeTime(val))
+	},
+	format: formatTime,
+	toNumber: func(t time.Time) float64 {
+		hour, min, sec := t.Clock()
+		return float64(((hour*60+min)*60+sec)*1000 + t.Nanosecond()/1e6)
+	},
+	fromNumber: func(number float64) time.Time {
+		return time.Date(0, time.January, 1, 0, 0, 0, int(number)*1e6, time.UTC)
+	},
+	stepScale:   1000,
+	defaultStep: 60,
+}
+
+var dateTimeLocalFormat = temporalFormat{
+	parse: func(val string) (time.Time, bool) {
+		m := patternDateTimeLocal.FindStringSubmatch(val)
+		if m == nil {
+			return time.Time{}, false
+		}
+		return parseLayout(nil, "2006-01-02T15:04:05", m[1]+"T"+normalizeTime(m[2]))
+	},
+	format: func(t time.Time) string {
+		return t.Format(ISO8601Date) + "T" + formatTime(t)
+	},
+	toNumber:    unixMilliseconds,
+	fromNumber:  fromUnixMilliseconds,
+	stepScale:   1000,
+	defaultStep: 60,
+}
+
+var monthFormat = temporalFormat{
+	parse: func(val string) (time.Time, bool) {
+		return parseLayout(patternMonth, ISO8601Month, val)
+	},
+	format: func(t time.Time) string {
+		return t.Format(ISO8601Month)
+	},
+	toNumber: func(t time.Time) float64 {
+		return float64((t.Year()-1970)*12 + int(t.Month()) - 1)
+	},
+	fromNumber: func(number float64) time.Time {
+		return time.Date(1970, time.Month(int(number)+1), 1, 0, 0, 0, 0, time.UTC)
+	},
+	stepScale:   1,
+	defaultStep: 1,
+}
+
+var weekFormat = temporalFormat{
+	parse: func(val string) (time.Time, bool) {
+		m := patternWeek.FindStringSubmatch(val)
+		if m == nil {
+			return time.Time{}, false
+		}
+		year, _ := strconv.Atoi(m[1])
+		week, _ := strconv.Atoi(m[2])
+		if week < 1 || week > weeksInYear(year) {
+			return time.Time{}, false
+		}
+		return weekStart(year, week), true
+	},
+	format: func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	},
+	toNumber:    unixMilliseconds,
+	fromNumber:  fromUnixMilliseconds,
+	stepScale:   millisecondsPerWeek,
+	defaultStep: 1,
+	// Monday of 1970-W01
+	defaultStepBase: -3 * millisecondsPerDay,
+}
+
+func unixMilliseconds(t time.Time) float64 {
+	return float64(t.Unix())*1000 + float64(t.Nanosecond()/1e6)
+}
+
+// Converts milliseconds to time. Seconds are passed separately, because
+// nanoseconds since the epoch only fit in an int64 between 1678 and 2262.
+func fromUnixMilliseconds(number float64) time.Time {
+	milliseconds := int64(number)
+	return time.Unix(milliseconds/1000, milliseconds%1000*1e6).UTC()
+}
+
+// Parses a value and converts it to a number.
+func (format temporalFormat) parseNumber(val string) (float64, bool) {
+	t, ok := format.parse(val)
+	if !ok {
+		return 0, false
+	}
+	return format.toNumber(t), true
+}
+
+func (format temporalFormat) formatNumber(number float64) string {
+	return format.format(format.fromNumber(number))
+}
+
+func parseLayout(pattern *regexp.Regexp, layout string, val string) (time.Time, bool) {
+	if pattern != nil && !pattern.MatchString(val) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(layout, val)
+	return t, err == nil
+}
+
+// Adds missing seconds so the time can always be parsed using the same
+// layout. Fractions of a second are parsed automatically.
+func normalizeTime(val string) string {
+	if len(val) == len(ISO8601Time) {
+		return val + ":00"
+	}
+	return val
+}
+
+// Formats the time of day in the shortest form from the HTML spec, omitting
+// seconds and milliseconds when they are zero.
+func formatTime(t time.Time) string {
+	switch {
+	case t.Nanosecond() != 0:
+		return strings.TrimRight(t.Format("15:04:05.000"), "0")
+	case t.Second() != 0:
+		return t.Format("15:04:05")
+	}
+	return t.Format(ISO8601Time)
+}
+
+// Returns the Monday of an ISO 8601 week.
+func weekStart(year int, week int) time.Time {
+	// January 4th is always in the first week of the year
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	offset := (int(jan4.Weekday()) + 6) % 7
+	return jan4.AddDate(0, 0, -offset+(week-1)*7)
+}
+
+// Returns the number of ISO 8601 weeks in a year, either 52 or 53.
+func weeksInYear(year int) int {
+	// December 28th is always in the last week of the year
+	_, week := time.Date(year, time.December, 28, 0, 0, 0, 0, time.UTC).ISOWeek()
+	return week
+}
+
+// Date and time inputs validate the format of the value, as well as min, max
+// and step constraints. AutoFill picks the valid value closest to the current
+// time (see WithClock).
+type temporalInput struct {
+	anyInput
+	stepRange
+}
+
+func createTemporalInput(anyInput anyInput, n *html.Node, format temporalFormat) temporalInput {
+	return temporalInput{
+		anyInput: anyInput,
+		stepRange: createStepRange(
+			n,
+			format.parseNumber,
+			format.defaultStep,
+			format.stepScale,
+			format.defaultStepBase,
+		),
+	}
+}
+
+// Returns the normalized value, or an error describing the violated
+// constraint.
+func (i temporalInput) check(format temporalFormat, val string) (value string, err *FieldError) {
+	number, ok := format.parseNumber(val)
+	if !ok {
+		err = newFieldError(ReasonType, "value has invalid format")
+		return
+	}
+	value = format.formatNumber(number)
+	err = i.checkRange(number, format.formatNumber)
+	if err != nil {
+		switch err.Reason {
+		case ReasonMin:
+			err.Message = fmt.Sprintf("value is before min '%s'", format.formatNumber(i.min))
+		case ReasonMax:
+			err.Message = fmt.Sprintf("value is after max '%s'", format.formatNumber(i.max))
+		}
+	}
+	return
+}
+
+func (i temporalInput) generate(format temporalFormat, g *generator) []string {
+	now, _ := format.parseNumber(format.format(g.now()))
+	number, ok := i.closest(now)
+	if !ok {
+		return nil
+	}
+	return []string{format.formatNumber(number)}
+}
+
+type DateInput struct {
+	temporalInput
+}
+
+func (i DateInput) Fill(val string) (value string, ok bool) {
+	value, err := i.check(val)
+	return value, err == nil
+}
+
+func (i DateInput) check(val string) (string, *FieldError) {
+	return i.temporalInput.check(dateFormat, val)
+}
+
+func (i DateInput) AutoFill() []string {
+	return i.generate(newGenerator())
+}
+
+func (i DateInput) generate(g *generator) []string {
+	return i.temporalInput.generate(dateFormat, g)
+}
+
+// Time inputs with min after max accept values in an overnight range, like
+// min="22:00" max="06:00", as described in the HTML spec.
+type TimeInput struct {
+	temporalInput
+}
+
+func (i TimeInput) Fill(val string) (value string, ok bool) {
+	value, err := i.check(val)
+	return value, err == nil
+}
+
+func (i TimeInput) check(val string) (string, *FieldError) {
+	late, early, ok := i.split()
+	if !ok {
+		return i.temporalInput.check(timeFormat, val)
+	}
+	value, err := late.check(timeFormat, val)
+	if err == nil || err.Reason != ReasonMin {
+		return value, err
+	}
+	value, err = early.check(timeFormat, val)
+	if err != nil && err.Reason == ReasonMax {
+		err.Message = fmt.Sprintf("value is not between min '%s' and max '%s'",
+			timeFormat.formatNumber(i.min), timeFormat.formatNumber(i.max))
+	}
+	return value, err
+}
+
+// Splits a reversed range into the part before midnight and the part after
+// midnight. The step base stays the same for both parts. Returns false when
+// the range is not reversed.
+func (i TimeInput) split() (late temporalInput, early temporalInput, ok bool) {
+	if !i.hasMin || !i.hasMax || i.min <= i.max {
+		return i.temporalInput, i.temporalInput, false
+	}
+	late, early = i.temporalInput, i.temporalInput
+	late.max = millisecondsPerDay - 1
+	early.min = 0
+	return late, early, true
+}
+
+func (i TimeInput) AutoFill() []string {
+	return i.generate(newGenerator())
+}
+
+func (i TimeInput) generate(g *generator) []string {
+	late, early, ok := i.split()
+	if !ok {
+		return i.temporalInput.generate(timeFormat, g)
+	}
+	now, _ := timeFormat.parseNumber(timeFormat.format(g.now()))
+	if now <= i.max {
+		late, early = early, late
+	}
+	if value := late.generate(timeFormat, g); value != nil {
+		return value
+	}
+	return early.generate(timeFormat, g)
+}
+
+type DateTimeLocalInput struct {
+	temporalInput
+}
+
+func (i DateTimeLocalInput) Fill(val string) (value string, ok bool) {
+	value, err := i.check(val)
+	return value, err == nil
+}
+
+func (i DateTimeLocalInput) check(val string) (string, *FieldError) {
+	return i.temporalInput.check(dateTimeLocalFormat, val)
+}
+
+func (i DateTimeLocalInput) AutoFill() []string {
+	return i.generate(newGenerator())
+}
+
+func (i DateTimeLocalInput) generate(g *generator) []string {
+	return i.temporalInput.generate(dateTimeLocalFormat, g)
+}
+
+type MonthInput struct {
+	temporalInput
+}
+
+func (i MonthInput) Fill(val string) (value string, ok bool) {
+	value, err := i.check(val)
+	return value, err == nil
+}
+
+func (i MonthInput) check(val string) (string, *FieldError) {
+	return i.temporalInput.check(monthFormat, val)
+}
+
+func (i MonthInput) AutoFill() []string {
+	return i.generate(newGenerator())
+}
+
+func (i MonthInput) generate(g *generator) []string {
+	return i.temporalInput.generate(monthFormat, g)
+}
+
+type WeekInput struct {
+	temporalInput
+}
+
+func (i WeekInput) Fill(val string) (value string, ok bool) {
+	value, err := i.check(val)
+	return value, err == nil
+}
+
+func (i WeekInput) check(val string) (string, *FieldError) {
+	return i.temporalInput.check(weekFormat, val)
+}
+
+func (i WeekInput) AutoFill() []string {
+	return i.generate(newGenerator())
+}
+
+func (i WeekInput) generate(g *generator) []string {
+	return i.temporalInput.generate(weekFormat, g)
+}
@@ -2,6 +2,7 @@ package gosubmit
 
 import (
 	"net/http"
+	"net/http/httptest"
 )
 
 type test interface {
@@ -27,3 +28,10 @@ func (f TestingForm) NewTestRequest(opts ...Option) *http.Request {
 	f.assertNoError(err)
 	return r
 }
+
+func (f TestingForm) SubmitTo(handler http.Handler, opts ...Option) *httptest.ResponseRecorder {
+	f.t.Helper()
+	w, err := f.form.SubmitTo(handler, opts...)
+	f.assertNoError(err)
+	return w
+}
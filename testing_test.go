@@ -2,7 +2,9 @@ package gosubmit
 
 import (
 	"fmt"
+	"net/http"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -76,3 +78,19 @@ func TestTesting_fail(t *testing.T) {
 		t.Errorf("Expected log entry to match '%s', but was '%s'", re, log)
 	}
 }
+
+func TestTesting_SubmitTo(t *testing.T) {
+	doc := Parse(strings.NewReader(`<form method="post" action="/test"><input name="firstName"></form>`))
+
+	mock := &testMock{}
+	w := doc.FirstForm().Testing(mock).SubmitTo(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.FormValue("firstName")))
+	}), Set("firstName", "John"))
+
+	if mock.failed {
+		t.Errorf("Should not fail, but got %v", mock.log)
+	}
+	if body := w.Body.String(); body != "John" {
+		t.Errorf("Expected body to be 'John', but was '%s'", body)
+	}
+}
@@ -1,10 +1,10 @@
 package gosubmit
 
 import (
-	"math/rand"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
-	"time"
 )
 
 func atoi(str string) int {
@@ -16,17 +16,49 @@ func itoa(value int) string {
 	return strconv.Itoa(value)
 }
 
-const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+var patternNumber = regexp.MustCompile(`^-?(\d+|\d*\.\d+)([eE][-+]?\d+)?$`)
 
-var randomSource = rand.NewSource(time.Now().UnixNano())
+// Parses a valid floating-point number as defined by the HTML spec.
+func parseNumber(str string) (value float64, ok bool) {
+	if !patternNumber.MatchString(str) {
+		return
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	ok = err == nil && !math.IsInf(value, 0)
+	return
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// Rounds away errors introduced by floating point arithmetic, so that
+// 0.1 + 0.2 is 0.3.
+func roundNumber(value float64) float64 {
+	value, _ = strconv.ParseFloat(strconv.FormatFloat(value, 'g', 15, 64), 64)
+	return value
+}
+
+// Returns the length of the string in UTF-16 code units, the way browsers
+// measure minlength and maxlength.
+func utf16Length(str string) (length int) {
+	for _, r := range str {
+		// characters outside of the basic multilingual plane are encoded
+		// as surrogate pairs
+		if r > 0xffff {
+			length++
+		}
+		length++
+	}
+	return
+}
 
-func randomString(size int) string {
-	lettersCount := len(letters)
-	var b strings.Builder
-	b.Grow(size)
-	for i := 0; i < size; i++ {
-		index := rand.Intn(lettersCount)
-		b.WriteByte(letters[index])
+// Converts all line breaks to CRLF, like browsers do when submitting forms.
+func normalizeNewlines(str string) string {
+	if !strings.ContainsAny(str, "\r\n") {
+		return str
 	}
-	return b.String()
+	str = strings.ReplaceAll(str, "\r\n", "\n")
+	str = strings.ReplaceAll(str, "\r", "\n")
+	return strings.ReplaceAll(str, "\n", "\r\n")
 }
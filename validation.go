@@ -0,0 +1,88 @@
+package gosubmit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reason describes which constraint of a field was violated.
+type Reason string
+
+const (
+	ReasonRequired    Reason = "required"
+	ReasonPattern     Reason = "pattern"
+	ReasonMinLength   Reason = "minlength"
+	ReasonMaxLength   Reason = "maxlength"
+	ReasonMin         Reason = "min"
+	ReasonMax         Reason = "max"
+	ReasonStep        Reason = "step"
+	ReasonType        Reason = "type"
+	ReasonNotAnOption Reason = "not-an-option"
+	ReasonReadOnly    Reason = "readonly"
+)
+
+// FieldError describes a single field which failed validation.
+type FieldError struct {
+	// Name of the field
+	Name string
+	// The rejected value. Empty for missing required values.
+	Value  string
+	Reason Reason
+	// Human readable description of the violated constraint
+	Message string
+}
+
+func newFieldError(reason Reason, format string, values ...interface{}) *FieldError {
+	return &FieldError{
+		Reason:  reason,
+		Message: fmt.Sprintf(format, values...),
+	}
+}
+
+func (e FieldError) Error() string {
+	if e.Reason == ReasonRequired {
+		return fmt.Sprintf("Required field '%s' has no value", e.Name)
+	}
+	return fmt.Sprintf("Value '%s' for input name='%s' is invalid: %s", e.Value, e.Name, e.Message)
+}
+
+// ValidationError is returned when one or more fields are invalid. Use
+// errors.As to inspect it:
+//
+//	var validationErr *ValidationError
+//	if errors.As(err, &validationErr) && validationErr.Has("age", ReasonMin) {
+//		// ...
+//	}
+type ValidationError struct {
+	// All invalid fields. Fields with invalid values come first, in the order
+	// they were set, followed by missing required fields in document order.
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Returns true if the field failed validation for the reason.
+func (e *ValidationError) Has(name string, reason Reason) bool {
+	for _, field := range e.Fields {
+		if field.Name == name && field.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the validation errors of a field.
+func (e *ValidationError) Field(name string) (fields []FieldError) {
+	for _, field := range e.Fields {
+		if field.Name == name {
+			fields = append(fields, field)
+		}
+	}
+	return
+}